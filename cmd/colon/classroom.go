@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/8manuel/colongo/colon"
+)
+
+// studentOut is a student in the classroom command output.
+type studentOut struct {
+	Name     string `json:"name"`
+	BaseSeed string `json:"baseSeed"`
+	Salt     string `json:"salt"`
+}
+
+func runClassroom(a *app, args []string) error {
+	fs := flagSet("classroom")
+	path := fs.String("file", "classroom.json", "classroom file (it has the student base seeds, keep it private)")
+	addr := fs.String("addr", "localhost:8080", "address of the dashboard (serve)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("a classroom subcommand is required: create, add, list, provision or serve")
+	}
+	sub, subArgs := fs.Arg(0), fs.Args()[1:]
+
+	if sub == "create" {
+		if len(subArgs) != 1 {
+			return fmt.Errorf("create: one classroom name is required")
+		}
+		c, err := colon.CreateClassroom(*path, subArgs[0])
+		if err != nil {
+			return err
+		}
+		return a.result(map[string]string{"name": c.Name(), "salt": c.Salt()}, "created classroom "+c.Name()+" salt "+c.Salt())
+	}
+	c, err := colon.OpenClassroom(*path)
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "add", "list":
+		students := []colon.Student{}
+		if sub == "add" {
+			if len(subArgs) == 0 {
+				return fmt.Errorf("add: at least one student name is required")
+			}
+			if students, err = c.AddStudents(subArgs...); err != nil {
+				return err
+			}
+		} else {
+			students = c.Students()
+		}
+		outs, lines := []studentOut{}, []string{}
+		for _, st := range students {
+			outs = append(outs, studentOut{st.Name, st.BaseSeed, c.Salt()})
+			lines = append(lines, fmt.Sprintf("%s -base-seed %s -salt %s", st.Name, st.BaseSeed, c.Salt()))
+		}
+		return a.result(outs, lines...)
+	case "provision":
+		if len(subArgs) != 1 {
+			return fmt.Errorf("provision: one scenario file is required")
+		}
+		sc, err := colon.LoadScenario(subArgs[0])
+		if err != nil {
+			return err
+		}
+		results, err := colon.MClassProvision(c, sc)
+		outs, lines := []map[string]interface{}{}, []string{}
+		for _, r := range results {
+			status := "exists"
+			if r.Funded {
+				status = "funded"
+			} else if r.Err != nil {
+				status = r.Err.Error()
+			}
+			outs = append(outs, map[string]interface{}{"student": r.Student, "account": r.Account, "address": r.Address, "status": status})
+			lines = append(lines, fmt.Sprintf("%s %s %s %s", r.Student, r.Account, r.Address, status))
+		}
+		if rerr := a.result(outs, lines...); rerr != nil {
+			return rerr
+		}
+		return err
+	case "serve":
+		if len(subArgs) == 0 {
+			return fmt.Errorf("serve: at least one scenario file is required")
+		}
+		scenarios := []*colon.Scenario{}
+		for _, p := range subArgs {
+			sc, err := colon.LoadScenario(p)
+			if err != nil {
+				return err
+			}
+			scenarios = append(scenarios, sc)
+		}
+		fmt.Fprintln(a.out, "dashboard of", c.Name(), "at http://"+*addr+"/")
+		return http.ListenAndServe(*addr, colon.NewDashboard(c, scenarios...))
+	}
+	return fmt.Errorf("unknown classroom subcommand %q", sub)
+}
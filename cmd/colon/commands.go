@@ -0,0 +1,671 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+// keyOut is a keypair in the command results, Seed is only set when it is asked.
+type keyOut struct {
+	Name     string `json:"name,omitempty"`
+	Address  string `json:"address"`
+	Seed     string `json:"seed,omitempty"`
+	Mnemonic string `json:"mnemonic,omitempty"`
+}
+
+// receiptOut is a transaction receipt in the command results.
+type receiptOut struct {
+	Hash        string   `json:"hash"`
+	Ledger      int32    `json:"ledger"`
+	FeeCharged  string   `json:"fee_charged"`
+	TxCode      string   `json:"tx_code"`
+	OpCodes     []string `json:"op_codes"`
+	EnvelopeXDR string   `json:"envelope_xdr"`
+	ResultXDR   string   `json:"result_xdr"`
+	DecodeError string   `json:"decode_error,omitempty"`
+}
+
+// receipt writes the receipt of a transaction.
+func (a *app) receipt(r colon.Receipt) error {
+	out := receiptOut{Hash: r.Hash, Ledger: r.Ledger, FeeCharged: r.FeeCharged.String(), TxCode: string(r.TxCode), OpCodes: []string{},
+		EnvelopeXDR: r.EnvelopeXDR, ResultXDR: r.ResultXDR}
+	for _, c := range r.OpCodes {
+		out.OpCodes = append(out.OpCodes, string(c))
+	}
+	lines := []string{"hash " + r.Hash, "ledger " + strconv.Itoa(int(r.Ledger)), "fee " + out.FeeCharged + " XLM", "result " + out.TxCode + " " + strings.Join(out.OpCodes, ",")}
+	if r.DecodeErr != nil {
+		out.DecodeError = r.DecodeErr.Error()
+		lines = append(lines, "the transaction was applied but its result could not be decoded: "+out.DecodeError)
+	}
+	return a.result(out, lines...)
+}
+
+// xdrArg returns the xdr argument, "-" reads it from stdin.
+func xdrArg(fs interface{ Args() []string }) (string, error) {
+	if len(fs.Args()) != 1 {
+		return "", fmt.Errorf("one xdr argument (or - to read it from stdin) is required")
+	}
+	if fs.Args()[0] != "-" {
+		return fs.Args()[0], nil
+	}
+	data, err := ioutil.ReadAll(os.Stdin)
+	return strings.TrimSpace(string(data)), err
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+func runKeygen(a *app, args []string) error {
+	fs := flagSet("keygen")
+	words := fs.Int("words", 0, "generate a mnemonic of 12, 15, 18, 21 or 24 words instead of a keypair (the address is its account 0)")
+	store := fs.String("store", "", "add the keypair to the keystore with this label instead of showing the seed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	out := keyOut{}
+	var pair *keypair.Full
+	var err error
+	if *words > 0 {
+		if out.Mnemonic, err = colon.NewMnemonic(*words); err != nil {
+			return err
+		}
+		md, err := colon.NewMnemonicDeriver(out.Mnemonic, "")
+		if err != nil {
+			return err
+		}
+		if pair, err = md.Account(0); err != nil {
+			return err
+		}
+	} else if pair, err = keypair.Random(); err != nil {
+		return err
+	}
+	out.Address = pair.Address()
+
+	// the mnemonic is always shown, it is the only backup of the key
+	lines := []string{"address " + out.Address}
+	if out.Mnemonic != "" {
+		lines = append(lines, "mnemonic "+out.Mnemonic)
+	}
+	if *store != "" {
+		ks, err := a.openKeystoreMode(true)
+		if err != nil {
+			return err
+		}
+		if err = ks.Add(*store, pair); err != nil {
+			return err
+		}
+		out.Name = *store
+		lines = append(lines, "stored in the keystore as ks:"+*store)
+	} else if out.Mnemonic == "" {
+		out.Seed = pair.Seed()
+		lines = append(lines, "seed "+out.Seed)
+	}
+	return a.result(out, lines...)
+}
+
+func runDerive(a *app, args []string) error {
+	fs := flagSet("derive")
+	showSeed := fs.Bool("seed", false, "show the seeds")
+	sep5 := fs.Bool("sep5", false, "the arguments are account indexes of the mnemonic in $COLON_MNEMONIC")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("at least one account name (or index with -sep5) is required")
+	}
+
+	outs, lines := []keyOut{}, []string{}
+	for _, name := range fs.Args() {
+		var pair *keypair.Full
+		var err error
+		if *sep5 {
+			n, perr := strconv.ParseUint(name, 10, 32)
+			if perr != nil {
+				return fmt.Errorf("wrong account index %q", name)
+			}
+			pair, err = a.mnemonicKey(uint32(n))
+		} else {
+			kd, kerr := a.keyDeriver()
+			if kerr != nil {
+				return kerr
+			}
+			pair, err = kd.Derive(name)
+		}
+		if err != nil {
+			return err
+		}
+		out := keyOut{Name: name, Address: pair.Address()}
+		line := name + " " + out.Address
+		if *showSeed {
+			out.Seed = pair.Seed()
+			line += " " + out.Seed
+		}
+		outs, lines = append(outs, out), append(lines, line)
+	}
+	return a.result(outs, lines...)
+}
+
+func runFund(a *app, args []string) error {
+	fs := flagSet("fund")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	outs, lines := []keyOut{}, []string{}
+	for _, ref := range fs.Args() {
+		addr, err := a.address(ref)
+		if err != nil {
+			return err
+		}
+		funded, err := colon.MEnsureFunded(addr)
+		if err != nil {
+			return err
+		}
+		status := "funded "
+		if !funded {
+			status = "already exists "
+		}
+		outs, lines = append(outs, keyOut{Address: addr}), append(lines, status+addr)
+	}
+	return a.result(outs, lines...)
+}
+
+// balanceOut is a balance in the balance command result.
+type balanceOut struct {
+	Asset      string `json:"asset"`
+	Balance    string `json:"balance"`
+	Limit      string `json:"limit,omitempty"`
+	Available  string `json:"available"`
+	Authorized bool   `json:"authorized"`
+}
+
+func runBalance(a *app, args []string) error {
+	fs := flagSet("balance")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	outs, lines := map[string][]balanceOut{}, []string{}
+	for _, ref := range fs.Args() {
+		addr, err := a.address(ref)
+		if err != nil {
+			return err
+		}
+		bals, err := colon.MLoadBalances(addr)
+		if err != nil {
+			return err
+		}
+		outs[addr] = []balanceOut{}
+		lines = append(lines, addr)
+		for _, asset := range sortedAssets(bals) {
+			b := bals[asset]
+			out := balanceOut{Asset: asset.String(), Balance: b.Balance.String(), Available: b.Available.String(), Authorized: b.Authorized}
+			if !asset.IsNative() {
+				out.Limit = b.Limit.String()
+			}
+			outs[addr] = append(outs[addr], out)
+			lines = append(lines, fmt.Sprintf("  %s %s (available %s)", out.Asset, out.Balance, out.Available))
+		}
+	}
+	return a.result(outs, lines...)
+}
+
+// sortedAssets returns the assets of the balances sorted by code and issuer, XLM first.
+func sortedAssets(bals map[colon.Asset]colon.Balance) (assets []colon.Asset) {
+	for asset := range bals {
+		assets = append(assets, asset)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		if assets[i].IsNative() != assets[j].IsNative() {
+			return assets[i].IsNative()
+		}
+		if assets[i].Code != assets[j].Code {
+			return assets[i].Code < assets[j].Code
+		}
+		return assets[i].Issuer < assets[j].Issuer
+	})
+	return assets
+}
+
+func runPay(a *app, args []string) error {
+	fs := flagSet("pay")
+	from := fs.String("from", "", "key of the source account")
+	to := fs.String("to", "", "destination address")
+	amt := fs.String("amount", "", "amount")
+	asset := fs.String("asset", "", "asset code (empty for XLM)")
+	issuer := fs.String("issuer", "", "asset issuer address (default the source account)")
+	memo := fs.String("memo", "", "memo text")
+	check := fs.Bool("check", true, "check the transaction before sending it (no fee is paid if it would fail)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	source, err := a.signer(*from)
+	if err != nil {
+		return err
+	}
+	dest, err := a.address(*to)
+	if err != nil {
+		return err
+	}
+	amount, err := colon.ParseAmount(*amt)
+	if err != nil {
+		return err
+	}
+	var pb build.PaymentBuilder
+	if *asset == "" {
+		pb = build.Payment(build.Destination{dest}, build.NativeAmount{amount.String()})
+	} else {
+		iss := source.Address()
+		if *issuer != "" {
+			if iss, err = a.address(*issuer); err != nil {
+				return err
+			}
+		}
+		pb = build.Payment(build.Destination{dest}, build.CreditAmount{*asset, iss, amount.String()})
+	}
+	muts := []build.TransactionMutator{pb}
+	if *memo != "" {
+		muts = append(muts, build.MemoText{*memo})
+	}
+	return a.send(source, *check, muts...)
+}
+
+// send builds the transaction of source with the operations muts, checks it (if check is set), signs it with source and sends it.
+func (a *app) send(source colon.Signer, check bool, muts ...build.TransactionMutator) error {
+	tb, err := colon.MTrans(source.Address(), muts...)
+	if err != nil {
+		return err
+	}
+	if err = colon.MOpsAdd(tb); err != nil {
+		return err
+	}
+	if check {
+		findings, err := colon.MPreflight(tb)
+		if err != nil {
+			return err
+		}
+		if err = colon.MPreflightError(findings); err != nil {
+			return err
+		}
+	}
+	resp, err := colon.MSignSubmit(source, tb)
+	if err != nil {
+		return err
+	}
+	return a.receipt(colon.NewReceipt(resp))
+}
+
+func runTrust(a *app, args []string) error {
+	fs := flagSet("trust")
+	key := fs.String("key", "", "key of the trusting account")
+	asset := fs.String("asset", "", "asset code")
+	issuer := fs.String("issuer", "", "asset issuer address")
+	limit := fs.String("limit", colon.Amount(math.MaxInt64).String(), "trustline limit (0 removes the trustline)")
+	check := fs.Bool("check", true, "check that the issuer exists before sending")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s, err := a.signer(*key)
+	if err != nil {
+		return err
+	}
+	iss, err := a.address(*issuer)
+	if err != nil {
+		return err
+	}
+	l, err := colon.ParseAmount(*limit)
+	if err != nil {
+		return err
+	}
+	r, err := colon.MTransTrust(s, *asset, iss, l, *check)
+	if err != nil {
+		return err
+	}
+	return a.receipt(r)
+}
+
+func runAllowTrust(a *app, args []string) error {
+	fs := flagSet("allow-trust")
+	key := fs.String("key", "", "key of the issuer account")
+	asset := fs.String("asset", "", "asset code")
+	trustor := fs.String("trustor", "", "address of the trusting account")
+	revoke := fs.Bool("revoke", false, "revoke the authorization instead of authorizing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s, err := a.signer(*key)
+	if err != nil {
+		return err
+	}
+	addr, err := a.address(*trustor)
+	if err != nil {
+		return err
+	}
+	r, err := colon.MAllowTrust(s, *asset, addr, !*revoke, true)
+	if err != nil {
+		return err
+	}
+	return a.receipt(r)
+}
+
+func runSetOptions(a *app, args []string) error {
+	fs := flagSet("set-options")
+	key := fs.String("key", "", "key of the account")
+	homeDomain := fs.String("home-domain", "", "home domain")
+	setFlags := fs.Uint("set-flags", 0, "flags to set (1 auth required, 2 auth revocable, 4 auth immutable)")
+	clearFlags := fs.Uint("clear-flags", 0, "flags to clear")
+	masterWeight := fs.Uint("master-weight", 0, "master key weight")
+	low := fs.Uint("low", 0, "low threshold")
+	med := fs.Uint("med", 0, "medium threshold")
+	high := fs.Uint("high", 0, "high threshold")
+	inflationDest := fs.String("inflation-dest", "", "inflation destination address")
+	signer := fs.String("signer", "", "signer ADDRESS:WEIGHT to add (weight 0 removes it)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s, err := a.signer(*key)
+	if err != nil {
+		return err
+	}
+	// only the options given in the command line are set
+	opts := map[string]interface{}{}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "home-domain":
+			opts["HomeDomain"] = *homeDomain
+		case "set-flags":
+			opts["SetFlags"] = uint32(*setFlags)
+		case "clear-flags":
+			opts["ClearFlags"] = uint32(*clearFlags)
+		case "master-weight":
+			opts["MasterWeight"] = uint32(*masterWeight)
+		case "low":
+			opts["LowThreshold"] = uint32(*low)
+		case "med":
+			opts["MedThreshold"] = uint32(*med)
+		case "high":
+			opts["HighThreshold"] = uint32(*high)
+		}
+	})
+	if *inflationDest != "" {
+		if opts["InflationDest"], err = publicKey(a, *inflationDest); err != nil {
+			return err
+		}
+	}
+	if *signer != "" {
+		i := strings.LastIndex(*signer, ":")
+		if i < 0 {
+			return fmt.Errorf("signer %q: ADDRESS:WEIGHT expected", *signer)
+		}
+		weight, err := strconv.ParseUint((*signer)[i+1:], 10, 8)
+		if err != nil {
+			return fmt.Errorf("signer %q: wrong weight", *signer)
+		}
+		pk, err := publicKey(a, (*signer)[:i])
+		if err != nil {
+			return err
+		}
+		opts["Signer"] = []interface{}{int32(xdr.SignerKeyTypeSignerKeyTypeEd25519), pk, uint32(weight)}
+	}
+	if len(opts) == 0 {
+		return fmt.Errorf("no option to set")
+	}
+	r, err := colon.MSetOptions(s, opts)
+	if err != nil {
+		return err
+	}
+	return a.receipt(r)
+}
+
+// publicKey returns the public key of an address (or key reference).
+func publicKey(a *app, ref string) (pk [32]byte, err error) {
+	addr, err := a.address(ref)
+	if err != nil {
+		return pk, err
+	}
+	raw, err := strkey.Decode(strkey.VersionByteAccountID, addr)
+	if err != nil {
+		return pk, err
+	}
+	copy(pk[:], raw)
+	return pk, nil
+}
+
+func runSign(a *app, args []string) error {
+	fs := flagSet("sign")
+	var keys stringList
+	fs.Var(&keys, "key", "key to sign with (can be repeated)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	data, err := xdrArg(fs)
+	if err != nil {
+		return err
+	}
+	signers, err := a.signers(keys)
+	if err != nil {
+		return err
+	}
+	if len(signers) == 0 {
+		return fmt.Errorf("at least one -key is required")
+	}
+	signed, err := colon.MSignXdr(data, signers...)
+	if err != nil {
+		return err
+	}
+	return a.result(map[string]string{"xdr": signed}, signed)
+}
+
+func runSubmit(a *app, args []string) error {
+	fs := flagSet("submit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	data, err := xdrArg(fs)
+	if err != nil {
+		return err
+	}
+	r, err := colon.MSubmitXdr(data)
+	if err != nil {
+		return err
+	}
+	return a.receipt(r)
+}
+
+// operationOut is an operation of a decoded transaction, Body is the xdr operation body.
+type operationOut struct {
+	Type   string      `json:"type"`
+	Source string      `json:"source,omitempty"`
+	Body   interface{} `json:"body"`
+}
+
+// envelopeOut is a decoded transaction envelope.
+type envelopeOut struct {
+	Hash       string         `json:"hash"`
+	Source     string         `json:"source"`
+	Fee        uint32         `json:"fee"`
+	Sequence   int64          `json:"sequence"`
+	Memo       interface{}    `json:"memo,omitempty"`
+	Operations []operationOut `json:"operations"`
+	Signatures int            `json:"signatures"`
+}
+
+func runDecode(a *app, args []string) error {
+	fs := flagSet("decode")
+	result := fs.Bool("result", false, "the xdr is a transaction result")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	data, err := xdrArg(fs)
+	if err != nil {
+		return err
+	}
+
+	if *result {
+		txCode, opCodes, err := colon.MResultXdrCodes(data)
+		if err != nil {
+			return err
+		}
+		codes := []string{}
+		for _, c := range opCodes {
+			codes = append(codes, string(c))
+		}
+		return a.result(map[string]interface{}{"tx_code": txCode, "op_codes": codes}, string(txCode)+" "+strings.Join(codes, ","))
+	}
+
+	tx, err := colon.MXdrToTrans(data)
+	if err != nil {
+		return err
+	}
+	hash, err := network.HashTransaction(&tx.Tx, colon.CurrentNetwork().Passphrase)
+	if err != nil {
+		return err
+	}
+	out := envelopeOut{Hash: hex.EncodeToString(hash[:]), Source: tx.Tx.SourceAccount.Address(), Fee: uint32(tx.Tx.Fee),
+		Sequence: int64(tx.Tx.SeqNum), Operations: []operationOut{}, Signatures: len(tx.Signatures)}
+	lines := []string{"hash " + out.Hash, "source " + out.Source, "fee " + strconv.Itoa(int(out.Fee)), "sequence " + strconv.FormatInt(out.Sequence, 10)}
+	if tx.Tx.Memo.Text != nil {
+		out.Memo = *tx.Tx.Memo.Text
+		lines = append(lines, "memo "+*tx.Tx.Memo.Text)
+	} else if tx.Tx.Memo.Id != nil {
+		out.Memo = uint64(*tx.Tx.Memo.Id)
+		lines = append(lines, "memo "+strconv.FormatUint(uint64(*tx.Tx.Memo.Id), 10))
+	}
+	for i, op := range tx.Tx.Operations {
+		o := operationOut{Type: op.Body.Type.String(), Body: op.Body}
+		line := fmt.Sprintf("operation %d %s", i, o.Type)
+		if op.SourceAccount != nil {
+			o.Source = op.SourceAccount.Address()
+			line += " source " + o.Source
+		}
+		out.Operations = append(out.Operations, o)
+		lines = append(lines, line)
+	}
+	lines = append(lines, "signatures "+strconv.Itoa(out.Signatures))
+	return a.result(out, lines...)
+}
+
+func runGrade(a *app, args []string) error {
+	fs := flagSet("grade")
+	namespace := fs.String("namespace", "", "the accounts are derived as NS/NAME")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("one scenario file is required")
+	}
+	sc, err := colon.LoadScenario(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	kd, err := a.keyDeriver()
+	if err != nil {
+		return err
+	}
+	addrs, err := colon.MScenarioAddresses(sc, kd, *namespace)
+	if err != nil {
+		return err
+	}
+	report, err := colon.MGrade(sc, addrs)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	colon.MGradePrint(&buf, report)
+	return a.result(report, strings.Split(strings.TrimSpace(buf.String()), "\n")...)
+}
+
+func runTeardown(a *app, args []string) error {
+	fs := flagSet("teardown")
+	collector := fs.String("collector", "", "address of the account that receives the XLM of the merged accounts")
+	var cosignerKeys stringList
+	fs.Var(&cosignerKeys, "cosigner", "key of a cosigner of the accounts (can be repeated)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *collector == "" || fs.NArg() == 0 {
+		return fmt.Errorf("the collector and at least one key are required")
+	}
+	dest, err := a.address(*collector)
+	if err != nil {
+		return err
+	}
+	pairs, err := a.signers(fs.Args())
+	if err != nil {
+		return err
+	}
+	cosigners, err := a.signers(cosignerKeys)
+	if err != nil {
+		return err
+	}
+	results, err := colon.MTeardownAll(pairs, dest, cosigners...)
+	outs, lines := []map[string]interface{}{}, []string{}
+	for _, r := range results {
+		status := "not found"
+		if r.Merged {
+			status = "merged"
+		} else if r.Err != nil {
+			status = r.Err.Error()
+		}
+		outs = append(outs, map[string]interface{}{"address": r.Address, "status": status, "hashes": r.Hashes})
+		lines = append(lines, r.Address+" "+status)
+	}
+	if rerr := a.result(outs, lines...); rerr != nil {
+		return rerr
+	}
+	return err
+}
+
+func runMerge(a *app, args []string) error {
+	fs := flagSet("merge")
+	key := fs.String("key", "", "key of the account to merge")
+	to := fs.String("to", "", "destination address")
+	cleanup := fs.Bool("cleanup", false, "remove the offers, trustlines, data and signers first (the balances are paid to the issuers)")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	var cosignerKeys stringList
+	fs.Var(&cosignerKeys, "cosigner", "key of a cosigner of the account (can be repeated)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := a.signer(*key)
+	if err != nil {
+		return err
+	}
+	dest, err := a.address(*to)
+	if err != nil {
+		return err
+	}
+	cosigners, err := a.signers(cosignerKeys)
+	if err != nil {
+		return err
+	}
+	confirm := func(source, dest string, balance colon.Amount) bool {
+		return *yes || a.confirm(fmt.Sprintf("merge %s (%s XLM) into %s?", source, balance, dest))
+	}
+	merged, r, err := colon.MAccountMerge(s, dest, colon.MergeOptions{Confirm: confirm, Cleanup: *cleanup, Cosigners: cosigners})
+	if err != nil {
+		return err
+	}
+	return a.result(map[string]string{"hash": r.Hash, "merged": merged.String()}, "hash "+r.Hash, "merged "+merged.String()+" XLM")
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// runCmd runs the command with the args as the colon tool does and returns its output; the flag errors are returned instead of exiting.
+func runCmd(a *app, name string, args ...string) (string, error) {
+	var buf bytes.Buffer
+	a.out = &buf
+	flagErrors, flagOutput = flag.ContinueOnError, ioutil.Discard
+	defer func() { flagErrors, flagOutput = flag.ExitOnError, os.Stderr }()
+	err := commands[name].run(a, args)
+	return buf.String(), err
+}
+
+// mockHorizon sets a mock horizon network that answers the requests with handler until the returned function is called.
+func mockHorizon(handler http.HandlerFunc) func() {
+	srv := httptest.NewServer(handler)
+	prev := colon.CurrentNetwork()
+	colon.SetNetwork(colon.Network{Name: "mock", Client: &horizon.Client{URL: srv.URL, HTTP: http.DefaultClient}, Passphrase: prev.Passphrase})
+	return func() {
+		colon.SetNetwork(prev)
+		srv.Close()
+	}
+}
+
+// accountsHandler answers the accounts addrs (100 XLM and 5 VEF of iss) and the last ledger, the other accounts do not exist.
+func accountsHandler(iss string, addrs ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr := strings.TrimPrefix(r.URL.Path, "/accounts/")
+		switch {
+		case containsString(addrs, addr):
+			fmt.Fprint(w, `{"id": "`+addr+`", "account_id": "`+addr+`", "sequence": "1", "subentry_count": 1, "balances": [
+				{"balance": "100.0000000", "asset_type": "native"},
+				{"balance": "5.0000000", "limit": "1000.0000000", "asset_type": "credit_alphanum4", "asset_code": "VEF", "asset_issuer": "`+iss+`"}]}`)
+		case r.URL.Path == "/ledgers":
+			fmt.Fprint(w, `{"_embedded": {"records": [{"base_reserve_in_stroops": 5000000}]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"type": "https://stellar.org/horizon-errors/not_found", "title": "Resource Missing", "status": 404}`)
+		}
+	}
+}
+
+func TestKeygen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "colon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("COLON_KEYSTORE_PASSWORD", "test password")
+	defer os.Unsetenv("COLON_KEYSTORE_PASSWORD")
+
+	// a random keypair shows the seed
+	out, err := runCmd(&app{}, "keygen")
+	if err != nil || !strings.HasPrefix(out, "address G") || !strings.Contains(out, "\nseed S") {
+		t.Errorf("keygen: %q %v", out, err)
+	}
+
+	// a stored mnemonic key shows the mnemonic (its only backup) but not the seed
+	a := &app{cfg: config{Keystore: filepath.Join(dir, "keys.json")}}
+	out, err = runCmd(a, "keygen", "-words", "12", "-store", "course/A")
+	if err != nil || !strings.Contains(out, "\nmnemonic ") || !strings.Contains(out, "stored in the keystore as ks:course/A") || strings.Contains(out, "seed") {
+		t.Errorf("keygen -words -store: %q %v", out, err)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "mnemonic ") && len(strings.Fields(line)) != 13 {
+			t.Errorf("mnemonic of %d words: %q", len(strings.Fields(line))-1, line)
+		}
+	}
+
+	// the JSON output has the mnemonic too
+	a = &app{json: true}
+	out, err = runCmd(a, "keygen", "-words", "24")
+	var k keyOut
+	if err != nil || json.Unmarshal([]byte(out), &k) != nil || len(strings.Fields(k.Mnemonic)) != 24 || k.Address == "" || k.Seed != "" {
+		t.Errorf("keygen -json -words: %q %v", out, err)
+	}
+
+	// flag errors are returned
+	if _, err = runCmd(&app{}, "keygen", "-words", "x"); err == nil {
+		t.Error("no error for -words x")
+	}
+	if _, err = runCmd(&app{}, "keygen", "-words", "13"); err == nil {
+		t.Error("no error for 13 words")
+	}
+}
+
+func TestDerive(t *testing.T) {
+	kd, err := colon.NewKeyDeriver("BaseDrillSeedStr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairA, err := kd.Derive("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// text output, with and without the seeds
+	a := &app{cfg: config{BaseSeed: "BaseDrillSeedStr"}}
+	out, err := runCmd(a, "derive", "A", "course/B")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if err != nil || len(lines) != 2 || lines[0] != "A "+pairA.Address() || !strings.HasPrefix(lines[1], "course/B G") {
+		t.Errorf("derive: %q %v", out, err)
+	}
+	out, err = runCmd(a, "derive", "-seed", "A")
+	if err != nil || strings.TrimSpace(out) != "A "+pairA.Address()+" "+pairA.Seed() {
+		t.Errorf("derive -seed: %q %v", out, err)
+	}
+
+	// JSON output
+	a.json = true
+	out, err = runCmd(a, "derive", "A")
+	var keys []keyOut
+	if err != nil || json.Unmarshal([]byte(out), &keys) != nil || len(keys) != 1 || keys[0].Name != "A" || keys[0].Address != pairA.Address() || keys[0].Seed != "" {
+		t.Errorf("derive -json: %q %v", out, err)
+	}
+
+	// errors: no names, no base seed, unknown flag, invalid name
+	for _, c := range []struct {
+		a    *app
+		args []string
+	}{{a, nil}, {&app{}, []string{"A"}}, {a, []string{"-unknown", "A"}}, {a, []string{"course//B"}}} {
+		if _, err = runCmd(c.a, "derive", c.args...); err == nil {
+			t.Error("no error for derive", c.args)
+		}
+	}
+}
+
+func TestDecodeResult(t *testing.T) {
+	results := []xdr.OperationResult{{Code: xdr.OperationResultCodeOpInner, Tr: &xdr.OperationResultTr{Type: xdr.OperationTypePayment, PaymentResult: &xdr.PaymentResult{Code: -5}}}}
+	b64, err := xdr.MarshalBase64(xdr.TransactionResult{FeeCharged: 100, Result: xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxFailed, Results: &results}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCmd(&app{}, "decode", "-result", b64)
+	if err != nil || strings.TrimSpace(out) != "tx_failed op_no_destination" {
+		t.Errorf("decode -result: %q %v", out, err)
+	}
+	out, err = runCmd(&app{json: true}, "decode", "-result", b64)
+	var codes struct {
+		TxCode  string   `json:"tx_code"`
+		OpCodes []string `json:"op_codes"`
+	}
+	if err != nil || json.Unmarshal([]byte(out), &codes) != nil || codes.TxCode != "tx_failed" || len(codes.OpCodes) != 1 || codes.OpCodes[0] != "op_no_destination" {
+		t.Errorf("decode -result -json: %q %v", out, err)
+	}
+
+	// an xdr argument is required, and it must be valid
+	if _, err = runCmd(&app{}, "decode", "-result"); err == nil {
+		t.Error("no error without xdr")
+	}
+	if _, err = runCmd(&app{}, "decode", "-result", "not xdr"); err == nil {
+		t.Error("no error for a wrong xdr")
+	}
+}
+
+func TestBalance(t *testing.T) {
+	kd, err := colon.NewKeyDeriver("BaseDrillSeedStr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairA, _ := kd.Derive("A")
+	pairI, _ := kd.Derive("I")
+	defer mockHorizon(accountsHandler(pairI.Address(), pairA.Address()))()
+
+	// the account is given by its key reference, the XLM available discounts the reserve of 2+1 entries
+	a := &app{cfg: config{BaseSeed: "BaseDrillSeedStr"}, json: true}
+	out, err := runCmd(a, "balance", "name:A")
+	var bals map[string][]balanceOut
+	if err != nil || json.Unmarshal([]byte(out), &bals) != nil || len(bals[pairA.Address()]) != 2 {
+		t.Fatalf("balance -json: %q %v", out, err)
+	}
+	for _, b := range bals[pairA.Address()] {
+		if b.Asset == "XLM" && (b.Balance != "100.0000000" || b.Available != "98.5000000") ||
+			b.Asset == "VEF:"+pairI.Address() && (b.Balance != "5.0000000" || b.Limit != "1000.0000000") {
+			t.Errorf("wrong balance %+v", b)
+		}
+	}
+
+	// the text output has XLM first and then the assets by code and issuer
+	a.json = false
+	out, err = runCmd(a, "balance", "name:A")
+	if lines := strings.Split(out, "\n"); err != nil || len(lines) < 3 || !strings.HasPrefix(lines[1], "  XLM ") || !strings.HasPrefix(lines[2], "  VEF:") {
+		t.Errorf("balance: %q %v", out, err)
+	}
+	assets := sortedAssets(map[colon.Asset]colon.Balance{{Code: "VEF", Issuer: "GB"}: {}, {Code: "EUR", Issuer: "GC"}: {}, {}: {}, {Code: "VEF", Issuer: "GA"}: {}})
+	if want := []colon.Asset{{}, {Code: "EUR", Issuer: "GC"}, {Code: "VEF", Issuer: "GA"}, {Code: "VEF", Issuer: "GB"}}; !reflect.DeepEqual(assets, want) {
+		t.Errorf("sorted assets %v, expected %v", assets, want)
+	}
+
+	// an account that does not exist is an error
+	if _, err = runCmd(&app{}, "balance", pairI.Address()); err == nil {
+		t.Error("no error for an account not found")
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	// the answer is read as a line, also with the \r of a terminal, and the prompt is written to w (stderr in the colon tool)
+	for answer, want := range map[string]bool{"y\n": true, "yes\r\n": true, "Y": true, "n\n": false, "\n": false, "": false, "yess\n": false} {
+		var out bytes.Buffer
+		a := &app{ask: askLine(&out, strings.NewReader(answer))}
+		if got := a.confirm("merge?"); got != want || out.String() != "merge? [y/N] " {
+			t.Errorf("answer %q: %v (prompt %q), expected %v", answer, got, out.String(), want)
+		}
+	}
+	if (&app{}).confirm("merge?") {
+		t.Error("confirmed without a way to ask")
+	}
+}
+
+func TestMergeNotConfirmed(t *testing.T) {
+	kd, err := colon.NewKeyDeriver("BaseDrillSeedStr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairA, _ := kd.Derive("A")
+	pairB, _ := kd.Derive("B")
+	pairI, _ := kd.Derive("I")
+	defer mockHorizon(accountsHandler(pairI.Address(), pairA.Address(), pairB.Address()))()
+
+	// the merge into itself or into an account that does not exist is refused before asking
+	var prompt string
+	a := &app{cfg: config{BaseSeed: "BaseDrillSeedStr"}, ask: func(p string) (string, error) {
+		prompt = p
+		return "n\r", nil
+	}}
+	if _, err = runCmd(a, "merge", "-key", "name:A", "-to", "name:A"); err == nil {
+		t.Error("no error merging an account into itself")
+	}
+	if _, err = runCmd(a, "merge", "-key", "name:A", "-to", "name:I", "-cosigner", "name:B"); !colon.IsAccountNotFound(err) {
+		t.Errorf("got %v, expected an AccountNotFoundError", err)
+	}
+	if _, err = runCmd(a, "merge", "-key", "name:A", "-to", "name:B", "-cosigner", "wrong"); err == nil {
+		t.Error("no error for a wrong cosigner key")
+	}
+	if prompt != "" {
+		t.Errorf("asked %q", prompt)
+	}
+
+	// the question is asked with the app ask function (the shell terminal) and the answer no does not merge
+	if _, err = runCmd(a, "merge", "-key", "name:A", "-to", "name:B", "-cosigner", "name:I", "-cleanup"); err != colon.ErrMergeNotConfirmed {
+		t.Errorf("got %v, expected ErrMergeNotConfirmed", err)
+	}
+	if !strings.HasPrefix(prompt, "merge "+pairA.Address()+" (100.0000000 XLM) into "+pairB.Address()+"?") {
+		t.Errorf("wrong question %q", prompt)
+	}
+}
+
+// containsString returns true if list contains s.
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/stellar/go/keypair"
+)
+
+// signer resolves a key reference (see the package doc) into a Signer.
+func (a *app) signer(ref string) (colon.Signer, error) {
+	kind, arg := "", ref
+	if i := strings.Index(ref, ":"); i >= 0 {
+		kind, arg = ref[:i], ref[i+1:]
+	}
+	switch kind {
+	case "":
+		if !strings.HasPrefix(ref, "S") {
+			return nil, fmt.Errorf("key %q: not a seed or a key reference (name:, ks:, sep5:, sock:)", ref)
+		}
+		return a.fullKey(ref)
+	case "name":
+		kd, err := a.keyDeriver()
+		if err != nil {
+			return nil, err
+		}
+		return kd.Derive(arg)
+	case "ks":
+		ks, err := a.openKeystore()
+		if err != nil {
+			return nil, err
+		}
+		return ks.Signer(arg)
+	case "sep5":
+		n, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: wrong account index", ref)
+		}
+		return a.mnemonicKey(uint32(n))
+	case "sock":
+		if a.cfg.SignerSocket == "" {
+			return nil, fmt.Errorf("key %q: no signer socket (-signer-socket)", ref)
+		}
+		return colon.SocketSigner{Network: "unix", Socket: a.cfg.SignerSocket, Addr: arg, Token: os.Getenv("COLON_SIGNER_TOKEN")}, nil
+	}
+	return nil, fmt.Errorf("key %q: unknown key reference %s:", ref, kind)
+}
+
+// signers resolves the key references into Signers.
+func (a *app) signers(refs []string) (signers []colon.Signer, err error) {
+	for _, ref := range refs {
+		s, err := a.signer(ref)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, s)
+	}
+	return signers, nil
+}
+
+// address resolves an address: a G... address or the address of a key reference.
+func (a *app) address(ref string) (string, error) {
+	if strings.HasPrefix(ref, "G") && !strings.Contains(ref, ":") {
+		return ref, nil
+	}
+	s, err := a.signer(ref)
+	if err != nil {
+		return "", err
+	}
+	return s.Address(), nil
+}
+
+// fullKey parses a seed.
+func (a *app) fullKey(seed string) (*keypair.Full, error) {
+	kp, err := keypair.Parse(seed)
+	if err != nil {
+		return nil, err
+	}
+	pair, ok := kp.(*keypair.Full)
+	if !ok {
+		return nil, fmt.Errorf("not a seed")
+	}
+	return pair, nil
+}
+
+// keyDeriver returns the KeyDeriver of the config base seed and salt.
+func (a *app) keyDeriver() (*colon.KeyDeriver, error) {
+	if a.deriver != nil {
+		return a.deriver, nil
+	}
+	if a.cfg.BaseSeed == "" {
+		return nil, fmt.Errorf("no base seed (-base-seed)")
+	}
+	var err error
+	if a.cfg.Salt == "" {
+		a.deriver, err = colon.NewKeyDeriver(a.cfg.BaseSeed)
+	} else {
+		a.deriver, err = colon.NewKeyDeriverV1(a.cfg.BaseSeed, a.cfg.Salt)
+	}
+	return a.deriver, err
+}
+
+// openKeystoreMode opens the config keystore with the password of $COLON_KEYSTORE_PASSWORD; with create it is created if it does not exist.
+func (a *app) openKeystoreMode(create bool) (*colon.Keystore, error) {
+	if a.keystore != nil {
+		return a.keystore, nil
+	}
+	if a.cfg.Keystore == "" {
+		return nil, fmt.Errorf("no keystore (-keystore)")
+	}
+	password := os.Getenv("COLON_KEYSTORE_PASSWORD")
+	ks, err := colon.OpenKeystore(a.cfg.Keystore, password)
+	if os.IsNotExist(err) && create {
+		ks, err = colon.CreateKeystore(a.cfg.Keystore, password)
+	}
+	a.keystore = ks
+	return ks, err
+}
+
+// openKeystore opens the config keystore, that must exist.
+func (a *app) openKeystore() (*colon.Keystore, error) {
+	return a.openKeystoreMode(false)
+}
+
+// mnemonicKey derives the account n of the mnemonic of $COLON_MNEMONIC.
+func (a *app) mnemonicKey(n uint32) (*keypair.Full, error) {
+	mnemonic := os.Getenv("COLON_MNEMONIC")
+	if mnemonic == "" {
+		return nil, fmt.Errorf("no mnemonic ($COLON_MNEMONIC)")
+	}
+	md, err := colon.NewMnemonicDeriver(mnemonic, os.Getenv("COLON_MNEMONIC_PASSPHRASE"))
+	if err != nil {
+		return nil, err
+	}
+	return md.Account(n)
+}
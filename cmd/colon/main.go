@@ -0,0 +1,287 @@
+// Command colon is the command line interface of the colon helpers: it creates keys, funds accounts, shows balances,
+// and builds, signs, sends and decodes transactions.
+//
+// Usage:
+//
+//	colon [global flags] <command> [command flags] [arguments]
+//
+// The global flags select the network and where the keys come from, they can also be set in a JSON config file (-config);
+// the flags override the config file. With -json the result of the command is written as JSON (errors too) for scripting.
+//
+// Commands:
+//
+//	keygen       generate a random keypair or a mnemonic
+//	derive       derive the keypairs of account names (base seed) or mnemonic indexes
+//	fund         create and fund accounts with the friendbot (test network)
+//	balance      show the balances of accounts
+//	pay          send a payment
+//	trust        create, change or remove a trustline
+//	allow-trust  authorize or revoke a trustline (issuer)
+//	set-options  set the account options
+//	sign         sign a transaction envelope xdr
+//	submit       send a signed transaction envelope xdr
+//	decode       decode a transaction envelope or result xdr
+//	grade        check the drill steps completed by the accounts of a base seed
+//	merge        merge an account into another one
+//	teardown     remove the subentries of accounts and merge them into a collector account
+//	shell        interactive shell to compose, sign and send transactions step by step
+//	classroom    manage the student base seeds of a classroom, provision their accounts and serve the dashboard
+//
+// Keys are referenced as:
+//
+//	S...         a seed (avoid it, it is kept in the shell history)
+//	name:NAME    the account NAME derived from the base seed (-base-seed, -salt)
+//	ks:LABEL     the entry LABEL of the keystore (-keystore, password in $COLON_KEYSTORE_PASSWORD)
+//	sep5:N       the account N of the mnemonic in $COLON_MNEMONIC (passphrase in $COLON_MNEMONIC_PASSPHRASE)
+//	sock:G...    the account signed by the signer process listening at -signer-socket (token in $COLON_SIGNER_TOKEN)
+//
+// Addresses are G... or any key reference (its address is used).
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// config is the config file content, the empty fields keep the defaults.
+type config struct {
+	Network      string `json:"network"`
+	Horizon      string `json:"horizon"`
+	Passphrase   string `json:"passphrase"`
+	Friendbot    string `json:"friendbot"`
+	BaseSeed     string `json:"baseSeed"`
+	Salt         string `json:"salt"`
+	Keystore     string `json:"keystore"`
+	SignerSocket string `json:"signerSocket"`
+}
+
+// command is a subcommand: its usage line, description and function.
+type command struct {
+	usage string
+	desc  string
+	run   func(a *app, args []string) error
+}
+
+// commands are the subcommands by name (set in init, the commands use it to show their usage).
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"keygen":      {"keygen [-words N] [-store LABEL]", "generate a random keypair or a mnemonic", runKeygen},
+		"derive":      {"derive [-seed] [-sep5] NAME|INDEX...", "derive the keypairs of account names (base seed) or mnemonic indexes", runDerive},
+		"fund":        {"fund ADDRESS...", "create and fund accounts with the friendbot (test network), the existing ones are skipped", runFund},
+		"balance":     {"balance ADDRESS...", "show the balances of accounts", runBalance},
+		"pay":         {"pay -from KEY -to ADDRESS -amount AMOUNT [-asset CODE [-issuer ADDRESS]] [-memo TEXT] [-check=false]", "send a payment", runPay},
+		"trust":       {"trust -key KEY -asset CODE -issuer ADDRESS [-limit AMOUNT] [-check=false]", "create, change (or remove with -limit 0) a trustline", runTrust},
+		"allow-trust": {"allow-trust -key KEY -asset CODE -trustor ADDRESS [-revoke]", "authorize or revoke a trustline (issuer)", runAllowTrust},
+		"set-options": {"set-options -key KEY [-home-domain D] [-set-flags F] [-clear-flags F] [-master-weight W] [-low T] [-med T] [-high T] [-inflation-dest ADDRESS] [-signer ADDRESS:WEIGHT]", "set the account options", runSetOptions},
+		"sign":        {"sign -key KEY [-key KEY...] XDR|-", "sign a transaction envelope xdr", runSign},
+		"submit":      {"submit XDR|-", "send a signed transaction envelope xdr", runSubmit},
+		"decode":      {"decode [-result] XDR|-", "decode a transaction envelope (or with -result a transaction result) xdr", runDecode},
+		"grade":       {"grade [-namespace NS] SCENARIO.json", "check the drill steps completed by the accounts of the base seed (-base-seed, -salt)", runGrade},
+		"classroom":   {"classroom [-file F] [-addr HOST:PORT] create NAME | add STUDENT... | list | provision SCENARIO.json | serve SCENARIO.json...", "manage the student base seeds of a classroom, provision their accounts and serve the dashboard", runClassroom},
+		"merge":       {"merge -key KEY -to ADDRESS [-cosigner KEY]... [-cleanup] [-yes]", "merge an account into another one after confirmation, showing the XLM transferred", runMerge},
+		"teardown":    {"teardown -collector ADDRESS [-cosigner KEY]... KEY...", "remove the offers, trustlines, data and signers of accounts and merge them into the collector", runTeardown},
+		"shell":       {"shell", "interactive shell to compose, sign and send transactions step by step (with history and tab completion)", runShell},
+	}
+}
+
+// app is the state shared by the commands: the config, the output format, how to ask the user and the key sources opened on demand.
+type app struct {
+	cfg      config
+	json     bool
+	out      io.Writer
+	ask      func(prompt string) (string, error) // writes the prompt and reads the answer, the shell reads it from its terminal
+	deriver  *colon.KeyDeriver
+	keystore *colon.Keystore
+}
+
+func main() {
+	a := &app{out: os.Stdout}
+	// the prompts go to stderr, so they are not mixed with the -json output
+	a.ask = askLine(os.Stderr, os.Stdin)
+	fs := flag.NewFlagSet("colon", flag.ContinueOnError)
+	fs.Usage = func() { usage(fs) }
+	cfgPath := fs.String("config", os.Getenv("COLON_CONFIG"), "JSON config file (default $COLON_CONFIG)")
+	network := fs.String("network", "", "network: test (default), public or custom (with -horizon and -passphrase)")
+	hURL := fs.String("horizon", "", "horizon server URL (default the network one)")
+	passphrase := fs.String("passphrase", "", "network passphrase (default the network one)")
+	baseSeed := fs.String("base-seed", "", "base seed of the name: keys")
+	salt := fs.String("salt", "", "salt of the name: keys, if set they are derived with the KDF based derivation (v1)")
+	keystore := fs.String("keystore", "", "keystore file of the ks: keys")
+	socket := fs.String("signer-socket", "", "unix socket of the signer process of the sock: keys")
+	fs.BoolVar(&a.json, "json", false, "write the result as JSON")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	// config file, overridden by the flags
+	if *cfgPath != "" {
+		data, err := ioutil.ReadFile(*cfgPath)
+		if err == nil {
+			err = json.Unmarshal(data, &a.cfg)
+		}
+		if err != nil {
+			a.fail(fmt.Errorf("config %s: %v", *cfgPath, err))
+		}
+	}
+	for _, f := range []struct{ dst, src *string }{{&a.cfg.Network, network}, {&a.cfg.Horizon, hURL}, {&a.cfg.Passphrase, passphrase},
+		{&a.cfg.BaseSeed, baseSeed}, {&a.cfg.Salt, salt}, {&a.cfg.Keystore, keystore}, {&a.cfg.SignerSocket, socket}} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if err := a.setNetwork(); err != nil {
+		a.fail(err)
+	}
+
+	// run the command
+	if fs.NArg() == 0 {
+		usage(fs)
+		os.Exit(2)
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown command", fs.Arg(0))
+		usage(fs)
+		os.Exit(2)
+	}
+	if err := cmd.run(a, fs.Args()[1:]); err != nil {
+		a.fail(err)
+	}
+}
+
+// usage writes the global flags and the commands.
+func usage(fs *flag.FlagSet) {
+	fmt.Fprintln(os.Stderr, "usage: colon [global flags] <command> [command flags] [arguments]\n\nglobal flags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].desc)
+	}
+}
+
+// setNetwork sets the colon package network from the config.
+func (a *app) setNetwork() error {
+	var n colon.Network
+	switch a.cfg.Network {
+	case "", "test":
+		n = colon.TestNet
+	case "public":
+		n = colon.PublicNet
+	case "custom":
+		if a.cfg.Horizon == "" || a.cfg.Passphrase == "" {
+			return fmt.Errorf("the custom network needs the horizon URL and the passphrase")
+		}
+		n = colon.Network{Name: "custom"}
+	default:
+		return fmt.Errorf("unknown network %q", a.cfg.Network)
+	}
+	if a.cfg.Horizon != "" {
+		n.Client = &horizon.Client{URL: strings.TrimRight(a.cfg.Horizon, "/"), HTTP: http.DefaultClient}
+	}
+	if a.cfg.Passphrase != "" {
+		n.Passphrase = a.cfg.Passphrase
+	}
+	if a.cfg.Friendbot != "" {
+		n.Friendbot = a.cfg.Friendbot
+	}
+	colon.SetNetwork(n)
+	return nil
+}
+
+// flagErrors and flagOutput are the error handling and output of the command flag sets (the shell does not exit on a flag error).
+var (
+	flagErrors           = flag.ExitOnError
+	flagOutput io.Writer = os.Stderr
+)
+
+// flagSet returns the flag set of a command, its usage shows the command usage line.
+func flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flagErrors)
+	fs.SetOutput(flagOutput)
+	fs.Usage = func() {
+		fmt.Fprintln(flagOutput, "usage: colon", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// result writes v as JSON with -json, otherwise the text lines.
+func (a *app) result(v interface{}, lines ...string) error {
+	if a.json {
+		enc := json.NewEncoder(a.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	for _, l := range lines {
+		fmt.Fprintln(a.out, l)
+	}
+	return nil
+}
+
+// askLine returns an ask function that writes the prompt to w and reads the answer line from r.
+func askLine(w io.Writer, r io.Reader) func(prompt string) (string, error) {
+	br := bufio.NewReader(r)
+	return func(prompt string) (string, error) {
+		fmt.Fprint(w, prompt)
+		line, err := br.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return line, err
+	}
+}
+
+// confirm asks the question and returns true if the answer is y or yes; without a way to ask the answer is no.
+func (a *app) confirm(question string) bool {
+	if a.ask == nil {
+		return false
+	}
+	answer, err := a.ask(question + " [y/N] ")
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return err == nil && (answer == "y" || answer == "yes")
+}
+
+// fail writes the error and exits with status 1.
+func (a *app) fail(err error) {
+	a.printError(os.Stderr, err)
+	os.Exit(1)
+}
+
+// printError writes the error (with the transaction and operation codes if it is a horizon error), as JSON to the output with -json or to w.
+func (a *app) printError(w io.Writer, err error) {
+	out := struct {
+		Error   string   `json:"error"`
+		TxCode  string   `json:"tx_code,omitempty"`
+		OpCodes []string `json:"op_codes,omitempty"`
+	}{Error: err.Error()}
+	if _, ok := err.(*horizon.Error); ok {
+		out.TxCode, out.OpCodes, _ = colon.MHorizonErrorResultCode(err)
+	}
+	if a.json {
+		json.NewEncoder(a.out).Encode(out)
+	} else {
+		msg := "error: " + out.Error
+		if out.TxCode != "" {
+			msg += " (" + out.TxCode + " " + strings.Join(out.OpCodes, ",") + ")"
+		}
+		fmt.Fprintln(w, msg)
+	}
+}
@@ -0,0 +1,562 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/stellar/go/build"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// shellPrompt is the prompt of the shell lines.
+const shellPrompt = "colon> "
+
+// shellHelp is the help of the shell commands (the colon commands can also be used).
+const shellHelp = `shell commands:
+  tx new SOURCE [-memo TEXT]    start a transaction of the source account
+  op create -to ADDRESS -amount AMOUNT [-source KEY]
+  op pay -to ADDRESS -amount AMOUNT [-asset CODE -issuer ADDRESS] [-source KEY]
+  op trust -asset CODE -issuer ADDRESS [-limit AMOUNT] [-source KEY]
+  op allow -trustor ADDRESS -asset CODE [-revoke] [-source KEY]
+  op options [-home-domain D] [-master-weight W] [-low T] [-med T] [-high T] [-signer ADDRESS:WEIGHT]
+             [-set-flags auth_required,auth_revocable] [-clear-flags ...] [-source KEY]
+                                add an operation to the transaction
+  tx show                       decode the transaction and check the signatures against the account thresholds
+  tx sign KEY...                sign the transaction
+  tx unsign                     remove the signatures (to add more operations)
+  tx xdr                        show the transaction envelope xdr
+  tx submit                     send the signed transaction
+  tx reset                      discard the transaction
+  names                         show the key references used (completed with tab)
+  history                       show the lines entered
+  help, exit
+colon commands (see colon -h): `
+
+// shell is the state of the interactive shell: the transaction being composed, the key references used (for the completion) and the history.
+type shell struct {
+	a       *app
+	out     io.Writer
+	tb      *build.TransactionBuilder
+	txe     *build.TransactionEnvelopeBuilder
+	refs    map[string]bool
+	history []string
+}
+
+func runShell(a *app, args []string) error {
+	fs := flagSet("shell")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	sh := &shell{a: a, refs: map[string]bool{}}
+
+	// with a terminal the lines are edited with history (up/down) and completion (tab), otherwise they are read from stdin
+	var readLine func() (string, error)
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer terminal.Restore(fd, state)
+		term := terminal.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}, shellPrompt)
+		term.AutoCompleteCallback = sh.complete
+		sh.out, readLine = term, term.ReadLine
+		// the questions (e.g. the merge confirmation) are read from the terminal, in raw mode stdin has no lines
+		a.ask = func(prompt string) (string, error) {
+			term.SetPrompt(prompt)
+			defer term.SetPrompt(shellPrompt)
+			return term.ReadLine()
+		}
+		fmt.Fprintln(term, "colon shell on the", colon.CurrentNetwork().Name, "network, help shows the commands")
+	} else {
+		sc := bufio.NewScanner(os.Stdin)
+		sh.out = os.Stdout
+		readLine = func() (string, error) {
+			if !sc.Scan() {
+				if sc.Err() != nil {
+					return "", sc.Err()
+				}
+				return "", io.EOF
+			}
+			return sc.Text(), nil
+		}
+		a.ask = func(prompt string) (string, error) {
+			fmt.Fprint(sh.out, prompt)
+			return readLine()
+		}
+	}
+	// the commands write to the shell and do not exit on a flag error
+	a.out, flagOutput, flagErrors = sh.out, sh.out, flag.ContinueOnError
+
+	for {
+		line, err := readLine()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		words, err := splitLine(line)
+		if err != nil {
+			fmt.Fprintln(sh.out, "error:", err)
+			continue
+		}
+		if len(words) == 0 {
+			continue
+		}
+		sh.history = append(sh.history, line)
+		if words[0] == "exit" || words[0] == "quit" {
+			return nil
+		}
+		if err = sh.run(words); err != nil && err != flag.ErrHelp {
+			a.printError(sh.out, err)
+			continue
+		}
+		sh.addRefs(words)
+	}
+}
+
+// run executes a shell command or a colon command.
+func (sh *shell) run(words []string) error {
+	switch words[0] {
+	case "help":
+		fmt.Fprintln(sh.out, shellHelp+strings.Join(commandNames(), ", "))
+		return nil
+	case "history":
+		for i, l := range sh.history {
+			fmt.Fprintf(sh.out, "%4d  %s\n", i+1, l)
+		}
+		return nil
+	case "names":
+		fmt.Fprintln(sh.out, strings.Join(sh.candidates(""), " "))
+		return nil
+	case "tx":
+		return sh.tx(words[1:])
+	case "op":
+		return sh.op(words[1:])
+	case "shell":
+		return fmt.Errorf("already in the shell")
+	}
+	cmd, ok := commands[words[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, help shows the commands", words[0])
+	}
+	return cmd.run(sh.a, words[1:])
+}
+
+// tx executes the tx subcommands.
+func (sh *shell) tx(args []string) (err error) {
+	if len(args) == 0 {
+		return fmt.Errorf("tx: new, show, sign, unsign, xdr, submit or reset expected")
+	}
+	if args[0] == "new" {
+		fs := sh.flagSet("tx new")
+		memo := fs.String("memo", "", "memo text")
+		if len(args) < 2 {
+			return fmt.Errorf("tx new: the source account is required")
+		}
+		if err = fs.Parse(args[2:]); err != nil {
+			return err
+		}
+		source, err := sh.a.address(args[1])
+		if err != nil {
+			return err
+		}
+		muts := []build.TransactionMutator{}
+		if *memo != "" {
+			muts = append(muts, build.MemoText{*memo})
+		}
+		tb, err := colon.MTrans(source, muts...)
+		if err != nil {
+			return err
+		}
+		sh.tb, sh.txe = tb, nil
+		fmt.Fprintln(sh.out, "transaction of", source, "sequence", tb.TX.SeqNum)
+		return nil
+	}
+	if sh.tb == nil {
+		return fmt.Errorf("no transaction, start one with tx new SOURCE")
+	}
+
+	switch args[0] {
+	case "show":
+		data, err := sh.envelopeXdr()
+		if err != nil {
+			return err
+		}
+		if err = runDecode(sh.a, []string{data}); err != nil {
+			return err
+		}
+		return sh.thresholds()
+	case "sign":
+		if len(args) < 2 {
+			return fmt.Errorf("tx sign: at least one key is required")
+		}
+		signers := []colon.Signer{}
+		for _, ref := range args[1:] {
+			s, err := sh.a.signer(ref)
+			if err != nil {
+				return err
+			}
+			signers = append(signers, s)
+		}
+		if sh.txe == nil {
+			txe, err := colon.MSign(sh.tb, signers...)
+			if err != nil {
+				return err
+			}
+			sh.txe = &txe
+		} else {
+			for _, s := range signers {
+				if err = colon.MSignAdd(sh.txe, s); err != nil {
+					return err
+				}
+			}
+		}
+		fmt.Fprintln(sh.out, "signatures", len(sh.txe.E.Signatures))
+		return nil
+	case "unsign":
+		sh.txe = nil
+		return nil
+	case "xdr":
+		data, err := sh.envelopeXdr()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(sh.out, data)
+		return nil
+	case "submit":
+		if sh.txe == nil {
+			return fmt.Errorf("the transaction is not signed, use tx sign KEY")
+		}
+		resp, err := colon.MSubmit(*sh.txe)
+		if err != nil {
+			return err
+		}
+		sh.tb, sh.txe = nil, nil
+		return sh.a.receipt(colon.NewReceipt(resp))
+	case "reset":
+		sh.tb, sh.txe = nil, nil
+		return nil
+	}
+	return fmt.Errorf("tx: unknown subcommand %q", args[0])
+}
+
+// envelope returns the signed envelope, or the envelope of the transaction without signatures if it is not signed.
+func (sh *shell) envelope() (txe build.TransactionEnvelopeBuilder, err error) {
+	if sh.txe != nil {
+		return *sh.txe, nil
+	}
+	err = txe.Mutate(sh.tb)
+	return txe, err
+}
+
+// envelopeXdr returns the xdr of the signed envelope, or of the transaction without signatures if it is not signed.
+func (sh *shell) envelopeXdr() (string, error) {
+	txe, err := sh.envelope()
+	if err != nil {
+		return "", err
+	}
+	return txe.Base64()
+}
+
+// thresholds writes the signature check of each source account of the transaction.
+func (sh *shell) thresholds() error {
+	txe, err := sh.envelope()
+	if err != nil {
+		return err
+	}
+	checks, err := colon.MSignatureCheck(*txe.E)
+	if err != nil {
+		return err
+	}
+	for _, c := range checks {
+		if !c.Exists {
+			fmt.Fprintln(sh.out, "account", c.Account, "..not found")
+			continue
+		}
+		status := "..ok"
+		if !c.OK {
+			status = "..not enough signatures"
+		}
+		fmt.Fprintln(sh.out, "account", c.Account, "needs", c.Level, "threshold", c.Threshold, "signed weight", c.Weight, status)
+		for _, s := range c.Unsigned {
+			fmt.Fprintln(sh.out, "  not signed by", s)
+		}
+	}
+	return nil
+}
+
+// op adds an operation to the transaction.
+func (sh *shell) op(args []string) (err error) {
+	if sh.tb == nil {
+		return fmt.Errorf("no transaction, start one with tx new SOURCE")
+	}
+	if sh.txe != nil {
+		return fmt.Errorf("the transaction is signed, use tx unsign to add operations")
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("op: create, pay, trust, allow or options expected")
+	}
+	fs := sh.flagSet("op " + args[0])
+	source := fs.String("source", "", "operation source account (default the transaction source)")
+	to := fs.String("to", "", "destination address (create, pay)")
+	amt := fs.String("amount", "", "amount (create, pay)")
+	asset := fs.String("asset", "", "asset code (pay, trust, allow)")
+	issuer := fs.String("issuer", "", "asset issuer address (pay, trust)")
+	limit := fs.String("limit", "", "trustline limit, default the maximum (trust)")
+	trustor := fs.String("trustor", "", "trustor address (allow)")
+	revoke := fs.Bool("revoke", false, "revoke the trustline (allow)")
+	homeDomain := fs.String("home-domain", "", "home domain (options)")
+	masterWeight := fs.Uint("master-weight", 0, "master key weight (options)")
+	low := fs.Uint("low", 0, "low threshold (options)")
+	med := fs.Uint("med", 0, "medium threshold (options)")
+	high := fs.Uint("high", 0, "high threshold (options)")
+	signer := fs.String("signer", "", "signer ADDRESS:WEIGHT, weight 0 removes it (options)")
+	setFlags := fs.String("set-flags", "", "flags to set: auth_required, auth_revocable (options)")
+	clearFlags := fs.String("clear-flags", "", "flags to clear (options)")
+	if err = fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	// the addresses of the flags that are given
+	addrs := map[string]string{}
+	for name, ref := range map[string]string{"source": *source, "to": *to, "issuer": *issuer, "trustor": *trustor} {
+		if ref != "" {
+			if addrs[name], err = sh.a.address(ref); err != nil {
+				return err
+			}
+		}
+	}
+	muts := []interface{}{}
+	if addrs["source"] != "" {
+		muts = append(muts, build.SourceAccount{addrs["source"]})
+	}
+
+	var op build.TransactionMutator
+	switch args[0] {
+	case "create":
+		a, err := colon.ParseAmount(*amt)
+		if err != nil {
+			return err
+		}
+		op = build.CreateAccount(append(muts, build.Destination{addrs["to"]}, build.NativeAmount{a.String()})...)
+	case "pay":
+		a, err := colon.ParseAmount(*amt)
+		if err != nil {
+			return err
+		}
+		if *asset == "" {
+			op = build.Payment(append(muts, build.Destination{addrs["to"]}, build.NativeAmount{a.String()})...)
+		} else {
+			op = build.Payment(append(muts, build.Destination{addrs["to"]}, build.CreditAmount{*asset, addrs["issuer"], a.String()})...)
+		}
+	case "trust":
+		if *limit != "" {
+			l, err := colon.ParseAmount(*limit)
+			if err != nil {
+				return err
+			}
+			muts = append(muts, build.Limit(l.String()))
+		}
+		op = build.Trust(*asset, addrs["issuer"], muts...)
+	case "allow":
+		op = build.AllowTrust(append(muts, build.Trustor{addrs["trustor"]}, build.AllowTrustAsset{Code: *asset}, build.Authorize{Value: !*revoke})...)
+	case "options":
+		for _, f := range strings.Split(*setFlags, ",") {
+			switch f {
+			case "":
+			case "auth_required":
+				muts = append(muts, build.SetAuthRequired())
+			case "auth_revocable":
+				muts = append(muts, build.SetAuthRevocable())
+			default:
+				return fmt.Errorf("set-flags: unknown flag %q (auth_required, auth_revocable)", f)
+			}
+		}
+		for _, f := range strings.Split(*clearFlags, ",") {
+			switch f {
+			case "":
+			case "auth_required":
+				muts = append(muts, build.ClearAuthRequired())
+			case "auth_revocable":
+				muts = append(muts, build.ClearAuthRevocable())
+			default:
+				return fmt.Errorf("clear-flags: unknown flag %q (auth_required, auth_revocable)", f)
+			}
+		}
+		thresholds := build.Thresholds{}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "home-domain":
+				muts = append(muts, build.HomeDomain(*homeDomain))
+			case "master-weight":
+				muts = append(muts, build.MasterWeight(uint32(*masterWeight)))
+			case "low":
+				v := uint32(*low)
+				thresholds.Low = &v
+			case "med":
+				v := uint32(*med)
+				thresholds.Medium = &v
+			case "high":
+				v := uint32(*high)
+				thresholds.High = &v
+			}
+		})
+		if thresholds.Low != nil || thresholds.Medium != nil || thresholds.High != nil {
+			muts = append(muts, thresholds)
+		}
+		if *signer != "" {
+			i := strings.LastIndex(*signer, ":")
+			if i < 0 {
+				return fmt.Errorf("signer %q: ADDRESS:WEIGHT expected", *signer)
+			}
+			weight, err := strconv.ParseUint((*signer)[i+1:], 10, 8)
+			if err != nil {
+				return fmt.Errorf("signer %q: wrong weight", *signer)
+			}
+			addr, err := sh.a.address((*signer)[:i])
+			if err != nil {
+				return err
+			}
+			muts = append(muts, build.AddSigner(addr, uint32(weight)))
+		}
+		op = build.SetOptions(muts...)
+	default:
+		return fmt.Errorf("op: unknown operation %q", args[0])
+	}
+	if err = colon.MOpsAdd(sh.tb, op); err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "operations", len(sh.tb.TX.Operations))
+	return nil
+}
+
+// flagSet returns a flag set of a shell command.
+func (sh *shell) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(sh.out)
+	return fs
+}
+
+// addRefs keeps the key references of a command that succeeded (and the names of derive) for the completion.
+func (sh *shell) addRefs(words []string) {
+	for i, w := range words {
+		if i > 0 && words[0] == "derive" && !strings.HasPrefix(w, "-") && !strings.Contains(w, ":") {
+			if _, err := strconv.Atoi(w); err != nil {
+				sh.refs["name:"+w] = true
+			} else {
+				sh.refs["sep5:"+w] = true
+			}
+		}
+		for _, prefix := range []string{"name:", "ks:", "sep5:", "sock:"} {
+			if strings.HasPrefix(w, prefix) && len(w) > len(prefix) {
+				sh.refs[w] = true
+			}
+		}
+	}
+}
+
+// candidates returns the key references used, the keystore labels and (for the first word) the commands that start with prefix.
+func (sh *shell) candidates(prefix string) (cands []string) {
+	all := map[string]bool{}
+	for ref := range sh.refs {
+		all[ref] = true
+	}
+	if sh.a.cfg.Keystore != "" && os.Getenv("COLON_KEYSTORE_PASSWORD") != "" {
+		if ks, err := sh.a.openKeystore(); err == nil {
+			for _, e := range ks.List() {
+				all["ks:"+e.Label] = true
+			}
+		}
+	}
+	for c := range all {
+		if strings.HasPrefix(c, prefix) {
+			cands = append(cands, c)
+		}
+	}
+	sort.Strings(cands)
+	return cands
+}
+
+// complete is the tab completion of the terminal: the commands for the first word and the key references for the others;
+// with several candidates the word is completed up to their common prefix.
+func (sh *shell) complete(line string, pos int, key rune) (newLine string, newPos int, ok bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	start := strings.LastIndex(line[:pos], " ") + 1
+	word := line[start:pos]
+	var cands []string
+	if strings.TrimSpace(line[:start]) == "" {
+		for _, name := range append([]string{"tx", "op", "help", "history", "names", "exit"}, commandNames()...) {
+			if strings.HasPrefix(name, word) {
+				cands = append(cands, name)
+			}
+		}
+	} else {
+		cands = sh.candidates(word)
+	}
+	if len(cands) == 0 {
+		return "", 0, false
+	}
+	completion := cands[0]
+	for _, c := range cands[1:] {
+		for !strings.HasPrefix(c, completion) {
+			completion = completion[:len(completion)-1]
+		}
+	}
+	rest := line[pos:]
+	if len(cands) == 1 {
+		// a complete word is followed by a space (added if the line does not have it already) and the cursor goes after it
+		if !strings.HasPrefix(rest, " ") {
+			rest = " " + rest
+		}
+		return line[:start] + completion + rest, start + len(completion) + 1, true
+	}
+	return line[:start] + completion + rest, start + len(completion), true
+}
+
+// commandNames returns the names of the colon commands.
+func commandNames() (names []string) {
+	for name := range commands {
+		if name != "shell" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitLine splits a line in words separated by spaces, a word with spaces can be quoted with "".
+func splitLine(line string) (words []string, err error) {
+	word, quoted, inWord := "", false, false
+	for _, c := range line {
+		switch {
+		case c == '"':
+			quoted, inWord = !quoted, true
+		case c == ' ' && !quoted:
+			if inWord {
+				words, word, inWord = append(words, word), "", false
+			}
+		default:
+			word, inWord = word+string(c), true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word)
+	}
+	return words, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/build"
+)
+
+func TestSplitLine(t *testing.T) {
+	cases := []struct {
+		line  string
+		words []string
+		fail  bool
+	}{
+		{"", nil, false},
+		{"pay -from name:A", []string{"pay", "-from", "name:A"}, false},
+		{"  tx   new  ", []string{"tx", "new"}, false},
+		{`op pay -memo "hello world"`, []string{"op", "pay", "-memo", "hello world"}, false},
+		{`a "" b`, []string{"a", "", "b"}, false},
+		{`a"b c"d`, []string{"ab cd"}, false},
+		{`tx memo "unterminated`, nil, true},
+	}
+	for _, c := range cases {
+		words, err := splitLine(c.line)
+		if (err != nil) != c.fail || !reflect.DeepEqual(words, c.words) {
+			t.Errorf("splitLine(%q) = %q %v, expected %q", c.line, words, err, c.words)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	sh := &shell{a: &app{}, refs: map[string]bool{"name:A": true, "name:B": true, "ks:issuer": true}}
+	cases := []struct {
+		line    string
+		pos     int
+		key     rune
+		newLine string
+		newPos  int
+		ok      bool
+	}{
+		{"ke", 2, 'a', "", 0, false},
+		{"ke", 2, '\t', "keygen ", 7, true},
+		{"si", 2, '\t', "sign ", 5, true},
+		{"s", 1, '\t', "s", 1, true},
+		{"zz", 2, '\t', "", 0, false},
+		{"pay -from name:", 15, '\t', "pay -from name:", 15, true},
+		{"pay -from name:A", 16, '\t', "pay -from name:A ", 17, true},
+		{"pay -from k -to x", 11, '\t', "pay -from ks:issuer -to x", 20, true},
+		{"pay -from zz", 12, '\t', "", 0, false},
+	}
+	for _, c := range cases {
+		newLine, newPos, ok := sh.complete(c.line, c.pos, c.key)
+		if newLine != c.newLine || newPos != c.newPos || ok != c.ok {
+			t.Errorf("complete(%q, %d) = %q %d %v, expected %q %d %v", c.line, c.pos, newLine, newPos, ok, c.newLine, c.newPos, c.ok)
+		}
+	}
+}
+
+func TestOpOptionsFlags(t *testing.T) {
+	// the flags are checked before adding the operation
+	sh := &shell{a: &app{}, out: ioutil.Discard, tb: &build.TransactionBuilder{}}
+	for _, args := range [][]string{{"-set-flags", "auth_requird"}, {"-clear-flags", "auth_revocable,immutable"}} {
+		if err := sh.op(append([]string{"options"}, args...)); err == nil || !strings.Contains(err.Error(), "unknown flag") {
+			t.Errorf("op options %q: %v, expected an unknown flag error", args, err)
+		}
+	}
+}
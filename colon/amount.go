@@ -0,0 +1,130 @@
+package colon
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/go-errors/errors"
+)
+
+//
+// AMOUNTS
+// Stellar stores the amounts as int64 stroops (1 unit = 10^7 stroops), Amount does the same so there are no rounding errors parsing floats.
+//
+
+// Amount is an asset amount in stroops.
+type Amount int64
+
+// One is the amount of one unit (1.0000000) in stroops.
+const One Amount = 10000000
+
+// MaxAmount is the largest amount, it is the limit of a trustline created without limit.
+const MaxAmount Amount = math.MaxInt64
+
+// amountDecimals is the number of decimals of an amount.
+const amountDecimals = 7
+
+// ErrAmountOverflow is returned when an amount does not fit in an int64 of stroops.
+var ErrAmountOverflow = errors.New("amount overflow")
+
+// ParseAmount parses a decimal string with up to 7 decimals (e.g. "96", "0.1", "1500.0000000") into an Amount.
+// Stellar has no negative amounts (payments, limits, balances), so a negative amount as "-5" is invalid.
+func ParseAmount(s string) (a Amount, err error) {
+	str := strings.TrimSpace(s)
+	if strings.HasPrefix(str, "-") {
+		return 0, errors.Errorf("invalid amount %q, it can not be negative", s)
+	}
+	// split the integer and the decimal parts
+	intPart, fracPart := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		intPart, fracPart = str[:i], str[i+1:]
+	}
+	if intPart == "" && fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, errors.Errorf("invalid amount %q", s)
+	}
+	if len(fracPart) > amountDecimals {
+		return 0, errors.Errorf("invalid amount %q, maximum %d decimals", s, amountDecimals)
+	}
+	// convert both parts to stroops checking the overflow
+	fracPart += strings.Repeat("0", amountDecimals-len(fracPart))
+	if intPart == "" {
+		intPart = "0"
+	}
+	i, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, ErrAmountOverflow
+	}
+	f, _ := strconv.ParseInt(fracPart, 10, 64)
+	if i > (math.MaxInt64-f)/int64(One) {
+		return 0, ErrAmountOverflow
+	}
+	return Amount(i*int64(One) + f), nil
+}
+
+// MustParseAmount is like ParseAmount but panics if s is not a valid amount; it is useful for constant amounts as "1500".
+func MustParseAmount(s string) Amount {
+	a, err := ParseAmount(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// isDigits returns true if all the characters of s are decimal digits.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount with 7 decimals, the same format used by horizon (e.g. "96.0000000").
+func (a Amount) String() string {
+	sign, u := "", uint64(a)
+	if a < 0 {
+		sign, u = "-", uint64(-(a+1))+1
+	}
+	return fmt.Sprintf("%s%d.%07d", sign, u/uint64(One), u%uint64(One))
+}
+
+// Add returns a+b or ErrAmountOverflow.
+func (a Amount) Add(b Amount) (Amount, error) {
+	r := a + b
+	if (b > 0 && r < a) || (b < 0 && r > a) {
+		return 0, ErrAmountOverflow
+	}
+	return r, nil
+}
+
+// Sub returns a-b or ErrAmountOverflow.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	r := a - b
+	if (b > 0 && r > a) || (b < 0 && r < a) {
+		return 0, ErrAmountOverflow
+	}
+	return r, nil
+}
+
+// Mul returns a*n or ErrAmountOverflow.
+func (a Amount) Mul(n int64) (Amount, error) {
+	r := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(n))
+	if !r.IsInt64() {
+		return 0, ErrAmountOverflow
+	}
+	return Amount(r.Int64()), nil
+}
+
+// Cmp compares a and b and returns -1 if a < b, 0 if a == b and +1 if a > b.
+func (a Amount) Cmp(b Amount) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package colon
+
+import (
+	"github.com/go-errors/errors"
+)
+
+//
+// BALANCES AND TRUSTLINES
+// Typed balances of an account keyed by Asset, so the drills can check "acc B balance 96 VEF" with exact amounts.
+//
+
+// Asset is a Stellar asset identified by its code and issuer address; XLM (the native asset) has empty code and issuer.
+type Asset struct {
+	Code   string
+	Issuer string
+}
+
+// XLM is the native asset.
+var XLM = Asset{}
+
+// IsNative returns true if the asset is XLM.
+func (a Asset) IsNative() bool {
+	return a.Code == "" && a.Issuer == ""
+}
+
+// String returns "XLM" for the native asset and "code:issuer" for the credit assets.
+func (a Asset) String() string {
+	if a.IsNative() {
+		return "XLM"
+	}
+	return a.Code + ":" + a.Issuer
+}
+
+// DefaultBaseReserve is the usual base reserve (0.5 XLM); the balances are computed with the base reserve of the network (see MBaseReserve).
+const DefaultBaseReserve Amount = 5000000
+
+// Balance is the balance of an asset in an account.
+//   - Limit is the trustline limit (zero for XLM)
+//   - Authorized is false only when the issuer has not authorized (or has revoked) the trustline; horizon servers that do not report it are taken as authorized
+//   - Available is the amount that can be spent: balance minus selling liabilities, and for XLM also minus the minimum balance (reserves)
+type Balance struct {
+	Asset
+	Balance            Amount
+	Limit              Amount
+	Authorized         bool
+	BuyingLiabilities  Amount
+	SellingLiabilities Amount
+	Available          Amount
+}
+
+// MLoadBalances returns the balances of the account addr keyed by asset; the XLM available is computed with the base reserve of the last ledger.
+func MLoadBalances(addr string) (bals map[Asset]Balance, err error) {
+	acc, err := loadAccountRaw(addr)
+	if err != nil {
+		return nil, err
+	}
+	baseReserve, err := MBaseReserve()
+	if err != nil {
+		return nil, err
+	}
+	bals = map[Asset]Balance{}
+	for _, hb := range acc.Balances {
+		var b Balance
+		if hb.Type != "native" {
+			b.Asset = Asset{Code: hb.Code, Issuer: hb.Issuer}
+			if b.Limit, err = ParseAmount(hb.Limit); err != nil {
+				return nil, err
+			}
+		}
+		b.Authorized = hb.IsAuthorized == nil || *hb.IsAuthorized
+		if b.Balance, err = ParseAmount(hb.Balance.Balance); err != nil {
+			return nil, err
+		}
+		// the liabilities are not reported by old horizon servers
+		if hb.BuyingLiabilities != "" {
+			if b.BuyingLiabilities, err = ParseAmount(hb.BuyingLiabilities); err != nil {
+				return nil, err
+			}
+		}
+		if hb.SellingLiabilities != "" {
+			if b.SellingLiabilities, err = ParseAmount(hb.SellingLiabilities); err != nil {
+				return nil, err
+			}
+		}
+		// available is balance-selling liabilities, for XLM also minus the minimum balance (2+subentries)*base reserve
+		b.Available = b.Balance - b.SellingLiabilities
+		if b.IsNative() {
+			b.Available -= Amount(2+acc.SubentryCount) * baseReserve
+		}
+		if b.Available < 0 {
+			b.Available = 0
+		}
+		bals[b.Asset] = b
+	}
+	return bals, nil
+}
+
+// MBalanceOf returns the balance of the asset in the account addr; if the account has no trustline to the asset it returns an error.
+func MBalanceOf(addr string, asset Asset) (bal Balance, err error) {
+	bals, err := MLoadBalances(addr)
+	if err != nil {
+		return bal, err
+	}
+	bal, ok := bals[asset]
+	if !ok {
+		return bal, errors.Errorf("account %s has no trustline to %s", addr, asset)
+	}
+	return bal, nil
+}
+
+// MBalance returns the balance amount of the account addr for the asset assCode issued by addrIss (empty assCode for XLM).
+// If the account has no trustline to the asset it returns an error.
+func MBalance(addr, assCode, addrIss string) (amt Amount, err error) {
+	asset := XLM
+	if assCode != "" {
+		asset = Asset{Code: assCode, Issuer: addrIss}
+	}
+	bal, err := MBalanceOf(addr, asset)
+	return bal.Balance, err
+}
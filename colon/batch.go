@@ -0,0 +1,210 @@
+package colon
+
+import (
+	"sync"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+)
+
+//
+// BATCH PAYMENTS
+// Stellar accepts up to 100 operations per transaction, so many payments can be sent paying a single fee per transaction instead of one per payment.
+//
+
+// MaxOpsPerTrans is the maximum number of operations that Stellar accepts in a transaction.
+const MaxOpsPerTrans = 100
+
+// MaxMemoText is the maximum length in bytes of a text memo.
+const MaxMemoText = 28
+
+// PaymentRow is one payment of a batch: destination address, asset code (empty for XLM), amount and optional memo.
+// As the memo belongs to the transaction and not to the operation, rows with different memos are never packed in the same transaction.
+type PaymentRow struct {
+	Dest   string
+	Asset  string
+	Amount Amount
+	Memo   string
+}
+
+// Payment row status values reported by MBatchPayment.
+const (
+	RowPaid    = "paid"
+	RowSkipped = "skipped"
+	RowFailed  = "failed"
+)
+
+// PaymentStatus is the result of a PaymentRow: the status, the transaction hash and ledger (if it was sent) and the failure codes (if any).
+type PaymentStatus struct {
+	Row    PaymentRow
+	Status string
+	Hash   string
+	Ledger int32
+	TxCode ResultCode
+	OpCode ResultCode
+	Err    error
+}
+
+// chunkHook is called with the rows of a chunk and the hash and sequence of its signed transaction just before it is submitted,
+// if it returns an error the chunk is not submitted and its rows fail with that error.
+type chunkHook func(rows []int, hash string, seq int64) error
+
+// paymentChunk is a group of row indexes (with the same memo) that are sent in the same transaction.
+type paymentChunk struct {
+	memo string
+	rows []int
+}
+
+// MBatchPayment sends the payments of rows from pairSource packing them in transactions of up to MaxOpsPerTrans operations.
+// As in MTransPayment the credit assets are issued by pairSource; before sending, the destinations are checked to exist and to have a trustline to the asset,
+// the rows that would fail are skipped (so no fees are paid), also the ones with a memo longer than MaxMemoText. The report has one PaymentStatus per row in the same order as rows;
+// if a destination cannot be loaded (other than not found) nothing is sent and the error is returned.
+// pairSource and the channels are Signers (e.g. keypairs). If channels are provided the transactions are sent in parallel, each channel account is the source of a transaction (it provides the sequence number and pays the fee)
+// while pairSource is the source of the payment operations; otherwise the transactions are sent one after the other from pairSource.
+func MBatchPayment(pairSource Signer, rows []PaymentRow, channels ...Signer) (report []PaymentStatus, err error) {
+	return batchPayment(pairSource, rows, nil, channels...)
+}
+
+// batchPayment is MBatchPayment calling before (if not nil) before submitting each chunk.
+func batchPayment(pairSource Signer, rows []PaymentRow, before chunkHook, channels ...Signer) (report []PaymentStatus, err error) {
+	report = make([]PaymentStatus, len(rows))
+	for i, r := range rows {
+		report[i].Row = r
+	}
+
+	// check memos, destinations and trustlines (the issuer has no trustline to its asset), each destination is loaded only once
+	accounts := map[string]*horizon.Account{}
+	for i, r := range rows {
+		if len(r.Memo) > MaxMemoText {
+			report[i].Status, report[i].Err = RowSkipped, errors.Errorf("memo longer than %d bytes", MaxMemoText)
+			continue
+		}
+		acc, ok := accounts[r.Dest]
+		if !ok {
+			a, err := loadAccount(r.Dest)
+			if err == nil {
+				acc = &a
+			} else if !IsAccountNotFound(err) {
+				return report, err
+			}
+			accounts[r.Dest] = acc
+		}
+		if acc == nil {
+			report[i].Status, report[i].OpCode = RowSkipped, OpNoDestination
+		} else if r.Asset != "" && r.Dest != pairSource.Address() && !hasTrustline(acc, r.Asset, pairSource.Address()) {
+			report[i].Status, report[i].OpCode = RowSkipped, OpNoTrust
+		}
+	}
+
+	// pack the remaining rows into chunks of the same memo with up to MaxOpsPerTrans operations
+	chunks := []paymentChunk{}
+	open := map[string]int{}
+	for i, r := range rows {
+		if report[i].Status == RowSkipped {
+			continue
+		}
+		c, ok := open[r.Memo]
+		if !ok || len(chunks[c].rows) == MaxOpsPerTrans {
+			chunks = append(chunks, paymentChunk{memo: r.Memo})
+			c = len(chunks) - 1
+			open[r.Memo] = c
+		}
+		chunks[c].rows = append(chunks[c].rows, i)
+	}
+
+	// without channels the source account sends all the chunks
+	if len(channels) == 0 {
+		channels = []Signer{pairSource}
+	}
+	// each channel sends its chunks one after the other (so the autosequence is right), and the channels run in parallel
+	var wg sync.WaitGroup
+	for ch := range channels {
+		wg.Add(1)
+		go func(ch int) {
+			defer wg.Done()
+			for c := ch; c < len(chunks); c += len(channels) {
+				sendPaymentChunk(pairSource, channels[ch], rows, chunks[c], report, before)
+			}
+		}(ch)
+	}
+	wg.Wait()
+
+	for _, s := range report {
+		if s.Status == RowFailed {
+			return report, errors.New("some batch payments failed")
+		}
+	}
+	return report, nil
+}
+
+// sendPaymentChunk builds, signs and submits the transaction of a chunk and fills the report of its rows; the chunks do not share rows so report can be written concurrently.
+func sendPaymentChunk(pairSource, pairChannel Signer, rows []PaymentRow, chunk paymentChunk, report []PaymentStatus, before chunkHook) {
+	// build the transaction with the channel as source account and one payment operation per row
+	muts := []build.TransactionMutator{}
+	if chunk.memo != "" {
+		muts = append(muts, build.MemoText{chunk.memo})
+	}
+	for _, i := range chunk.rows {
+		r := rows[i]
+		var pb build.PaymentBuilder
+		if r.Asset == "" {
+			pb = build.Payment(build.SourceAccount{pairSource.Address()}, build.Destination{r.Dest}, build.NativeAmount{r.Amount.String()})
+		} else {
+			pb = build.Payment(build.SourceAccount{pairSource.Address()}, build.Destination{r.Dest}, build.CreditAmount{r.Asset, pairSource.Address(), r.Amount.String()})
+		}
+		muts = append(muts, pb)
+	}
+	tb, err := MTrans(pairChannel.Address(), muts...)
+	if err == nil {
+		err = MOpsAdd(tb)
+	}
+	var txe build.TransactionEnvelopeBuilder
+	if err == nil {
+		if pairChannel.Address() == pairSource.Address() {
+			txe, err = MSign(tb, pairSource)
+		} else {
+			txe, err = MSign(tb, pairChannel, pairSource)
+		}
+	}
+	if err == nil && before != nil {
+		var hash string
+		if hash, err = tb.HashHex(); err == nil {
+			err = before(chunk.rows, hash, int64(tb.TX.SeqNum))
+		}
+	}
+	if err != nil {
+		for _, i := range chunk.rows {
+			report[i].Status, report[i].Err = RowFailed, err
+		}
+		return
+	}
+
+	// submit, the transaction is atomic so all the rows get the same result
+	logf(LevelInfo, "batch payment transaction", "payments", len(chunk.rows), "from", pairSource.Address(), "channel", pairChannel.Address())
+	resp, err := MSubmit(txe)
+	if err == nil {
+		for _, i := range chunk.rows {
+			report[i].Status, report[i].Hash, report[i].Ledger = RowPaid, resp.Hash, resp.Ledger
+		}
+		return
+	}
+	txCode, opCodes, _ := MErrorCodes(err)
+	logf(LevelWarn, "batch payment transaction failed", "txCode", txCode, "opCodes", opCodes, "err", err)
+	for n, i := range chunk.rows {
+		report[i].Status, report[i].TxCode, report[i].Err = RowFailed, txCode, err
+		if n < len(opCodes) {
+			report[i].OpCode = opCodes[n]
+		}
+	}
+}
+
+// hasTrustline returns true if the account has a trustline to the asset code issued by addrIss.
+func hasTrustline(acc *horizon.Account, assCode, addrIss string) bool {
+	for _, b := range acc.Balances {
+		if b.Code == assCode && b.Issuer == addrIss {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,304 @@
+package colon
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/go-errors/errors"
+)
+
+//
+// CLASSROOM
+// With the default base seed all the students derive the same drill accounts, a classroom gives each student a random base seed.
+// The classroom file is JSON with the name, the salt and the students with their base seeds (it has the seeds, keep it private):
+// each student derives the drill accounts with DerivationV1, e.g. go test -run TestDrill0FundAB flgBaseSeed=STUDENTSEED flgSalt=CLASSROOMSALT
+// MClassProvision funds the first accounts of a drill for every student and MClassStatus (and the Dashboard) show their balances and grades.
+//
+
+// studentSeedBytes is the random bytes of a student base seed (24 base32 characters).
+const studentSeedBytes = 15
+
+// Student is a classroom student and the base seed of their accounts.
+type Student struct {
+	Name     string `json:"name"`
+	BaseSeed string `json:"baseSeed"`
+}
+
+// classroomFile is the classroom file content.
+type classroomFile struct {
+	Name     string    `json:"name"`
+	Salt     string    `json:"salt"`
+	Students []Student `json:"students"`
+}
+
+// Classroom is an open classroom file, every change is written to the file; it is safe for concurrent use.
+type Classroom struct {
+	mu       sync.Mutex
+	path     string
+	file     classroomFile
+	derivers map[string]*KeyDeriver
+}
+
+// CreateClassroom creates a new classroom file without students, the salt is the name with a random suffix; it fails if the file already exists.
+func CreateClassroom(path, name string) (c *Classroom, err error) {
+	if err = ValidateAccountName(name); err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(path); err == nil {
+		return nil, errors.New("classroom " + path + " already exists")
+	}
+	suffix, err := randomSeed(5)
+	if err != nil {
+		return nil, err
+	}
+	c = &Classroom{path: path, file: classroomFile{Name: name, Salt: name + "-" + suffix, Students: []Student{}}, derivers: map[string]*KeyDeriver{}}
+	return c, c.save()
+}
+
+// OpenClassroom opens a classroom file.
+func OpenClassroom(path string) (c *Classroom, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c = &Classroom{path: path, derivers: map[string]*KeyDeriver{}}
+	if err = json.Unmarshal(data, &c.file); err != nil {
+		return nil, errors.New("classroom " + path + ": " + err.Error())
+	}
+	if c.file.Salt == "" {
+		return nil, errors.New("classroom " + path + ": empty salt")
+	}
+	return c, nil
+}
+
+// Name returns the classroom name.
+func (c *Classroom) Name() string {
+	return c.file.Name
+}
+
+// Salt returns the salt that the students use with their base seed.
+func (c *Classroom) Salt() string {
+	return c.file.Salt
+}
+
+// AddStudents adds the students (names as in ValidateAccountName) with a new random base seed and returns them; it fails if a name already exists.
+func (c *Classroom) AddStudents(names ...string) (students []Student, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seen := map[string]bool{}
+	for _, st := range c.file.Students {
+		seen[st.Name] = true
+	}
+	for _, name := range names {
+		if err = ValidateAccountName(name); err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, errors.New("student " + name + " already exists")
+		}
+		seen[name] = true
+		seed, err := randomSeed(studentSeedBytes)
+		if err != nil {
+			return nil, err
+		}
+		students = append(students, Student{Name: name, BaseSeed: seed})
+	}
+	c.file.Students = append(c.file.Students, students...)
+	return students, c.save()
+}
+
+// Students returns the students sorted by name.
+func (c *Classroom) Students() (students []Student) {
+	c.mu.Lock()
+	students = append(students, c.file.Students...)
+	c.mu.Unlock()
+	sort.Slice(students, func(i, j int) bool { return students[i].Name < students[j].Name })
+	return students
+}
+
+// Student returns the student with the name.
+func (c *Classroom) Student(name string) (st Student, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, st := range c.file.Students {
+		if st.Name == name {
+			return st, nil
+		}
+	}
+	return st, errors.New("student " + name + " not found")
+}
+
+// KeyDeriver returns the KeyDeriver of the student accounts (DerivationV1 with the student base seed and the classroom salt).
+func (c *Classroom) KeyDeriver(name string) (kd *KeyDeriver, err error) {
+	st, err := c.Student(name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	kd = c.derivers[name]
+	c.mu.Unlock()
+	if kd != nil {
+		return kd, nil
+	}
+	// scrypt takes a while, it is done without the lock
+	if kd, err = NewKeyDeriverV1(st.BaseSeed, c.file.Salt); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.derivers[name] = kd
+	c.mu.Unlock()
+	return kd, nil
+}
+
+// save writes the classroom to a temporary file (readable only by the user) and renames it.
+func (c *Classroom) save() error {
+	data, err := json.MarshalIndent(c.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// randomSeed returns n random bytes as base32 without padding.
+func randomSeed(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// ProvisionResult is the result of funding a student account, Err is set if it failed.
+type ProvisionResult struct {
+	Student string
+	Account string
+	Address string
+	Funded  bool
+	Err     error
+}
+
+// MClassProvision funds with the friendbot, for every student, the accounts of the fund steps at the start of the scenario (the accounts that exist are skipped),
+// so the students begin the drill at its first transaction; it returns the result of each account and an error if any failed.
+func MClassProvision(c *Classroom, sc *Scenario) (results []ProvisionResult, err error) {
+	accounts := []string{}
+	for _, s := range sc.Steps {
+		if s.Action != ActionFund {
+			break
+		}
+		accounts = append(accounts, s.Account)
+	}
+	failed := 0
+	for _, st := range c.Students() {
+		kd, err := c.KeyDeriver(st.Name)
+		if err != nil {
+			return results, err
+		}
+		for _, name := range accounts {
+			r := ProvisionResult{Student: st.Name, Account: name}
+			pair, err := kd.Derive(name)
+			if err != nil {
+				return results, err
+			}
+			r.Address = pair.Address()
+			if r.Err = checkAccount(r.Address); IsAccountNotFound(r.Err) {
+				r.Err = MFund(r.Address)
+				r.Funded = r.Err == nil
+			}
+			if r.Err != nil {
+				failed++
+				logf(LevelWarn, "classroom provision failed", "student", st.Name, "account", name, "err", r.Err)
+			}
+			results = append(results, r)
+		}
+	}
+	if failed > 0 {
+		return results, errors.Errorf("classroom %s: %d accounts not provisioned", c.Name(), failed)
+	}
+	return results, nil
+}
+
+// AccountStatus is the state of a student account: if it exists and its balances (XLM first, then the trustlines by code).
+type AccountStatus struct {
+	Name     string
+	Address  string
+	Exists   bool
+	Balances []Balance
+}
+
+// StudentStatus is the state of the accounts of a student and the grade of each scenario; Err is set if they could not be loaded.
+type StudentStatus struct {
+	Student  string
+	Accounts []AccountStatus
+	Grades   []GradeReport
+	Err      error `json:"-"`
+}
+
+// MClassStatus loads the accounts of the scenarios (all their account names) and grades the scenarios for every student.
+func MClassStatus(c *Classroom, scenarios ...*Scenario) (status []StudentStatus) {
+	names, seen := []string{}, map[string]bool{}
+	for _, sc := range scenarios {
+		for _, name := range sc.Accounts {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	for _, st := range c.Students() {
+		ss := StudentStatus{Student: st.Name}
+		ss.Err = studentStatus(c, st.Name, names, scenarios, &ss)
+		if ss.Err != nil {
+			logf(LevelWarn, "classroom status failed", "student", st.Name, "err", ss.Err)
+		}
+		status = append(status, ss)
+	}
+	return status
+}
+
+// studentStatus loads the accounts and the grades of a student into ss.
+func studentStatus(c *Classroom, student string, names []string, scenarios []*Scenario, ss *StudentStatus) (err error) {
+	kd, err := c.KeyDeriver(student)
+	if err != nil {
+		return err
+	}
+	addrs := map[string]string{}
+	for _, name := range names {
+		pair, err := kd.Derive(name)
+		if err != nil {
+			return err
+		}
+		addrs[name] = pair.Address()
+		as := AccountStatus{Name: name, Address: pair.Address()}
+		bals, err := MLoadBalances(as.Address)
+		if err == nil {
+			as.Exists = true
+			for _, b := range bals {
+				as.Balances = append(as.Balances, b)
+			}
+			sort.Slice(as.Balances, func(i, j int) bool {
+				a, b := as.Balances[i].Asset, as.Balances[j].Asset
+				return a.IsNative() || !b.IsNative() && (a.Code < b.Code || a.Code == b.Code && a.Issuer < b.Issuer)
+			})
+		} else if cerr := checkAccount(as.Address); !IsAccountNotFound(cerr) {
+			return err
+		}
+		ss.Accounts = append(ss.Accounts, as)
+	}
+	for _, sc := range scenarios {
+		report, err := MGrade(sc, addrs)
+		if err != nil {
+			return err
+		}
+		ss.Grades = append(ss.Grades, report)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package colon
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+//
+// RESULT CODES
+// The transaction and operation result codes as horizon reports them in the extras of a failed transaction (e.g. transaction:"tx_failed", operations:["op_no_trust"]).
+//
+
+// ResultCode is a horizon transaction (tx_*) or operation (op_*) result code.
+type ResultCode string
+
+// Transaction result codes.
+const (
+	TxSuccess             ResultCode = "tx_success"
+	TxFailed              ResultCode = "tx_failed"
+	TxTooEarly            ResultCode = "tx_too_early"
+	TxTooLate             ResultCode = "tx_too_late"
+	TxMissingOperation    ResultCode = "tx_missing_operation"
+	TxBadSeq              ResultCode = "tx_bad_seq"
+	TxBadAuth             ResultCode = "tx_bad_auth"
+	TxInsufficientBalance ResultCode = "tx_insufficient_balance"
+	TxNoAccount           ResultCode = "tx_no_source_account"
+	TxInsufficientFee     ResultCode = "tx_insufficient_fee"
+	TxBadAuthExtra        ResultCode = "tx_bad_auth_extra"
+	TxInternalError       ResultCode = "tx_internal_error"
+)
+
+// Operation result codes of the operations used in the drills.
+const (
+	OpSuccess          ResultCode = "op_success"
+	OpMalformed        ResultCode = "op_malformed"
+	OpBadAuth          ResultCode = "op_bad_auth"
+	OpNoSourceAccount  ResultCode = "op_no_source_account"
+	OpNoAccount        ResultCode = "op_no_account"
+	OpUnderfunded      ResultCode = "op_underfunded"
+	OpSrcNoTrust       ResultCode = "op_src_no_trust"
+	OpSrcNotAuthorized ResultCode = "op_src_not_authorized"
+	OpNoDestination    ResultCode = "op_no_destination"
+	OpNoTrust          ResultCode = "op_no_trust"
+	OpNotAuthorized    ResultCode = "op_not_authorized"
+	OpLineFull         ResultCode = "op_line_full"
+	OpNoIssuer         ResultCode = "op_no_issuer"
+	OpLowReserve       ResultCode = "op_low_reserve"
+	OpAlreadyExists    ResultCode = "op_already_exists"
+	OpInvalidLimit     ResultCode = "op_invalid_limit"
+	OpNoTrustline      ResultCode = "op_no_trustline"
+	OpNotRequired      ResultCode = "op_not_required"
+	OpCantRevoke       ResultCode = "op_cant_revoke"
+	OpHasSubEntries    ResultCode = "op_has_sub_entries"
+	OpImmutableSet     ResultCode = "op_immutable_set"
+	OpSeqNumTooFar     ResultCode = "op_seq_num_too_far"
+	OpSelfNotAllowed   ResultCode = "op_self_not_allowed"
+	OpUnknown          ResultCode = "op_unknown"
+)
+
+// Operation result codes of the offers, path payments, options, data, inflation and bump sequence operations.
+const (
+	OpTooFewOffers        ResultCode = "op_too_few_offers"
+	OpCrossSelf           ResultCode = "op_cross_self"
+	OpOverSourceMax       ResultCode = "op_over_source_max"
+	OpSellNoTrust         ResultCode = "op_sell_no_trust"
+	OpBuyNoTrust          ResultCode = "op_buy_no_trust"
+	OpSellNotAuthorized   ResultCode = "op_sell_not_authorized"
+	OpBuyNotAuthorized    ResultCode = "op_buy_not_authorized"
+	OpSellNoIssuer        ResultCode = "op_sell_no_issuer"
+	OpBuyNoIssuer         ResultCode = "op_buy_no_issuer"
+	OpOfferNotFound       ResultCode = "op_offer_not_found"
+	OpTooManySigners      ResultCode = "op_too_many_signers"
+	OpBadFlags            ResultCode = "op_bad_flags"
+	OpInvalidInflation    ResultCode = "op_invalid_inflation"
+	OpCantChange          ResultCode = "op_cant_change"
+	OpUnknownFlag         ResultCode = "op_unknown_flag"
+	OpThresholdOutOfRange ResultCode = "op_threshold_out_of_range"
+	OpBadSigner           ResultCode = "op_bad_signer"
+	OpInvalidHomeDomain   ResultCode = "op_invalid_home_domain"
+	OpNotTime             ResultCode = "op_not_time"
+	OpNotSupportedYet     ResultCode = "op_not_supported_yet"
+	OpDataNameNotFound    ResultCode = "op_data_name_not_found"
+	OpDataInvalidName     ResultCode = "op_data_invalid_name"
+	OpBadSeq              ResultCode = "op_bad_seq"
+)
+
+// txCodes maps the xdr transaction result codes to the horizon codes.
+var txCodes = map[int32]ResultCode{
+	0: TxSuccess, -1: TxFailed, -2: TxTooEarly, -3: TxTooLate, -4: TxMissingOperation, -5: TxBadSeq,
+	-6: TxBadAuth, -7: TxInsufficientBalance, -8: TxNoAccount, -9: TxInsufficientFee, -10: TxBadAuthExtra, -11: TxInternalError,
+}
+
+// opInnerCodes maps the xdr inner result codes (by operation type) to the horizon codes, zero is always op_success.
+var opInnerCodes = map[xdr.OperationType]map[int32]ResultCode{
+	xdr.OperationTypeCreateAccount: {-1: OpMalformed, -2: OpUnderfunded, -3: OpLowReserve, -4: OpAlreadyExists},
+	xdr.OperationTypePayment: {-1: OpMalformed, -2: OpUnderfunded, -3: OpSrcNoTrust, -4: OpSrcNotAuthorized, -5: OpNoDestination,
+		-6: OpNoTrust, -7: OpNotAuthorized, -8: OpLineFull, -9: OpNoIssuer},
+	xdr.OperationTypePathPayment: {-1: OpMalformed, -2: OpUnderfunded, -3: OpSrcNoTrust, -4: OpSrcNotAuthorized, -5: OpNoDestination,
+		-6: OpNoTrust, -7: OpNotAuthorized, -8: OpLineFull, -9: OpNoIssuer, -10: OpTooFewOffers, -11: OpCrossSelf, -12: OpOverSourceMax},
+	xdr.OperationTypeManageOffer: offerInnerCodes, xdr.OperationTypeCreatePassiveOffer: offerInnerCodes,
+	xdr.OperationTypeSetOptions: {-1: OpLowReserve, -2: OpTooManySigners, -3: OpBadFlags, -4: OpInvalidInflation, -5: OpCantChange,
+		-6: OpUnknownFlag, -7: OpThresholdOutOfRange, -8: OpBadSigner, -9: OpInvalidHomeDomain},
+	xdr.OperationTypeChangeTrust:  {-1: OpMalformed, -2: OpNoIssuer, -3: OpInvalidLimit, -4: OpLowReserve, -5: OpSelfNotAllowed},
+	xdr.OperationTypeAllowTrust:   {-1: OpMalformed, -2: OpNoTrustline, -3: OpNotRequired, -4: OpCantRevoke, -5: OpSelfNotAllowed},
+	xdr.OperationTypeAccountMerge: {-1: OpMalformed, -2: OpNoAccount, -3: OpImmutableSet, -4: OpHasSubEntries, -5: OpSeqNumTooFar},
+	xdr.OperationTypeInflation:    {-1: OpNotTime},
+	xdr.OperationTypeManageData:   {-1: OpNotSupportedYet, -2: OpDataNameNotFound, -3: OpLowReserve, -4: OpDataInvalidName},
+	xdr.OperationTypeBumpSequence: {-1: OpBadSeq},
+}
+
+// offerInnerCodes are the inner result codes of the manage offer and create passive offer operations.
+var offerInnerCodes = map[int32]ResultCode{-1: OpMalformed, -2: OpSellNoTrust, -3: OpBuyNoTrust, -4: OpSellNotAuthorized, -5: OpBuyNotAuthorized,
+	-6: OpLineFull, -7: OpUnderfunded, -8: OpCrossSelf, -9: OpSellNoIssuer, -10: OpBuyNoIssuer, -11: OpOfferNotFound, -12: OpLowReserve}
+
+// txResultCode returns the horizon code of an xdr transaction result code.
+func txResultCode(code xdr.TransactionResultCode) ResultCode {
+	if rc, ok := txCodes[int32(code)]; ok {
+		return rc
+	}
+	return ResultCode("tx_" + strconv.Itoa(int(code)))
+}
+
+// opResultCode returns the horizon code of an xdr operation result; the inner codes not mapped in opInnerCodes are returned as "op_inner_<code>"
+// and the results of unknown operation types (or without their inner result) as op_unknown.
+func opResultCode(r xdr.OperationResult) ResultCode {
+	switch r.Code {
+	case xdr.OperationResultCodeOpBadAuth:
+		return OpBadAuth
+	case xdr.OperationResultCodeOpNoAccount:
+		return OpNoSourceAccount
+	}
+	if r.Tr == nil {
+		return OpMalformed
+	}
+	var inner int32
+	known := false
+	tr := r.Tr
+	switch tr.Type {
+	case xdr.OperationTypeCreateAccount:
+		if known = tr.CreateAccountResult != nil; known {
+			inner = int32(tr.CreateAccountResult.Code)
+		}
+	case xdr.OperationTypePayment:
+		if known = tr.PaymentResult != nil; known {
+			inner = int32(tr.PaymentResult.Code)
+		}
+	case xdr.OperationTypePathPayment:
+		if known = tr.PathPaymentResult != nil; known {
+			inner = int32(tr.PathPaymentResult.Code)
+		}
+	case xdr.OperationTypeManageOffer:
+		if known = tr.ManageOfferResult != nil; known {
+			inner = int32(tr.ManageOfferResult.Code)
+		}
+	case xdr.OperationTypeCreatePassiveOffer:
+		if known = tr.CreatePassiveOfferResult != nil; known {
+			inner = int32(tr.CreatePassiveOfferResult.Code)
+		}
+	case xdr.OperationTypeSetOptions:
+		if known = tr.SetOptionsResult != nil; known {
+			inner = int32(tr.SetOptionsResult.Code)
+		}
+	case xdr.OperationTypeChangeTrust:
+		if known = tr.ChangeTrustResult != nil; known {
+			inner = int32(tr.ChangeTrustResult.Code)
+		}
+	case xdr.OperationTypeAllowTrust:
+		if known = tr.AllowTrustResult != nil; known {
+			inner = int32(tr.AllowTrustResult.Code)
+		}
+	case xdr.OperationTypeAccountMerge:
+		if known = tr.AccountMergeResult != nil; known {
+			inner = int32(tr.AccountMergeResult.Code)
+		}
+	case xdr.OperationTypeInflation:
+		if known = tr.InflationResult != nil; known {
+			inner = int32(tr.InflationResult.Code)
+		}
+	case xdr.OperationTypeManageData:
+		if known = tr.ManageDataResult != nil; known {
+			inner = int32(tr.ManageDataResult.Code)
+		}
+	case xdr.OperationTypeBumpSequence:
+		if known = tr.BumpSeqResult != nil; known {
+			inner = int32(tr.BumpSeqResult.Code)
+		}
+	}
+	if !known {
+		return OpUnknown
+	}
+	if inner == 0 {
+		return OpSuccess
+	}
+	if rc, ok := opInnerCodes[r.Tr.Type][inner]; ok {
+		return rc
+	}
+	return ResultCode("op_inner_" + strconv.Itoa(int(inner)))
+}
+
+// MResultXdrCodes decodes a base64 transaction result xdr (from a horizon response or from the extras of a horizon error) into the transaction and operation codes.
+func MResultXdrCodes(resultXdr string) (txCode ResultCode, opCodes []ResultCode, err error) {
+	var tr xdr.TransactionResult
+	if err = xdr.SafeUnmarshalBase64(resultXdr, &tr); err != nil {
+		return txCode, opCodes, err
+	}
+	results, _ := tr.Result.GetResults()
+	for _, r := range results {
+		opCodes = append(opCodes, opResultCode(r))
+	}
+	return txResultCode(tr.Result.Code), opCodes, nil
+}
+
+// MErrorCodes returns the result codes of the error of a failed transaction: a horizon.Error with the result codes (or the result xdr) in its extras.
+// If err is not a transaction failure it returns an error.
+func MErrorCodes(err error) (txCode ResultCode, opCodes []ResultCode, e error) {
+	herr, ok := err.(*horizon.Error)
+	if !ok {
+		if err == nil {
+			return txCode, opCodes, errors.New("no error")
+		}
+		return txCode, opCodes, errors.New("not a horizon.Error: " + err.Error())
+	}
+	if raw, ok := herr.Problem.Extras["result_codes"]; ok {
+		var rc struct {
+			Transaction ResultCode   `json:"transaction"`
+			Operations  []ResultCode `json:"operations"`
+		}
+		if e = json.Unmarshal(raw, &rc); e != nil {
+			return txCode, opCodes, e
+		}
+		return rc.Transaction, rc.Operations, nil
+	}
+	if raw, ok := herr.Problem.Extras["result_xdr"]; ok {
+		var resultXdr string
+		if e = json.Unmarshal(raw, &resultXdr); e != nil {
+			return txCode, opCodes, e
+		}
+		return MResultXdrCodes(resultXdr)
+	}
+	return txCode, opCodes, errors.New("horizon error without result codes: " + herr.Problem.Title)
+}
+
+// MSameCodes returns true if both lists have the same codes in the same order.
+func MSameCodes(a, b []ResultCode) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
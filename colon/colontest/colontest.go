@@ -0,0 +1,61 @@
+// Package colontest has assertion helpers for the drill tests: the expected result codes of a transaction and the expected balances.
+//
+//	_, err := colon.MTransPayment(pairA, pairB.Address(), "VEF", colon.One, false)
+//	colontest.ExpectTxCodes(t, err, colon.TxFailed, colon.OpNoTrust)
+//	colontest.ExpectBalance(t, pairB.Address(), colon.Asset{Code: "VEF", Issuer: pairA.Address()}, "96")
+package colontest
+
+import (
+	"testing"
+
+	"github.com/8manuel/colongo/colon"
+)
+
+// ExpectTxCodes checks that err is the failure of a transaction with the txCode and (if any are given) the opCodes, e.g. (t, err, "tx_failed", "op_no_trust");
+// with txCode tx_success it checks that err is nil. It reports the mismatch with t.Errorf and returns false.
+func ExpectTxCodes(t testing.TB, err error, txCode colon.ResultCode, opCodes ...colon.ResultCode) bool {
+	t.Helper()
+	if err == nil {
+		if txCode != colon.TxSuccess {
+			t.Errorf("transaction succeeded, expected %s %v", txCode, opCodes)
+			return false
+		}
+		return true
+	}
+	gotTx, gotOps, cerr := colon.MErrorCodes(err)
+	if cerr != nil {
+		t.Errorf("expected %s %v, got error: %v", txCode, opCodes, err)
+		return false
+	}
+	if gotTx != txCode || len(opCodes) > 0 && !colon.MSameCodes(gotOps, opCodes) {
+		t.Errorf("got %s %v, expected %s %v", gotTx, gotOps, txCode, opCodes)
+		return false
+	}
+	return true
+}
+
+// ExpectSuccess checks that err is nil (the transaction succeeded), otherwise it reports the error with its result codes.
+func ExpectSuccess(t testing.TB, err error) bool {
+	t.Helper()
+	return ExpectTxCodes(t, err, colon.TxSuccess)
+}
+
+// ExpectBalance checks that the account addr has amount (e.g. "96" or "96.5") of the asset (colon.XLM for lumens).
+func ExpectBalance(t testing.TB, addr string, asset colon.Asset, amount string) bool {
+	t.Helper()
+	want, err := colon.ParseAmount(amount)
+	if err != nil {
+		t.Errorf("wrong expected amount %q: %v", amount, err)
+		return false
+	}
+	bal, err := colon.MBalanceOf(addr, asset)
+	if err != nil {
+		t.Errorf("balance of %s %s: %v", addr, asset, err)
+		return false
+	}
+	if bal.Balance != want {
+		t.Errorf("balance of %s %s is %s, expected %s", addr, asset, bal.Balance, want)
+		return false
+	}
+	return true
+}
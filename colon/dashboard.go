@@ -0,0 +1,183 @@
+package colon
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//
+// DASHBOARD
+// A web page for the instructor with the balances, trustlines and drill grades of every student of a classroom (see MClassStatus).
+//	http.ListenAndServe("localhost:8080", colon.NewDashboard(class, drill0, drill1))
+// "/" is the page and "/status.json" the same data as JSON; the status is reloaded from horizon at most every Refresh (or with ?reload=1, ignored if
+// it was loaded less than MinReload ago). Only one load runs at a time, meanwhile the requests get the previous status.
+//
+
+// DefaultDashboardRefresh is the time the dashboard keeps the status before loading it again, DefaultDashboardMinReload the time a reload is ignored after a load.
+const (
+	DefaultDashboardRefresh   = 30 * time.Second
+	DefaultDashboardMinReload = 5 * time.Second
+)
+
+// Dashboard is an http.Handler with the status of a classroom.
+type Dashboard struct {
+	Refresh   time.Duration
+	MinReload time.Duration
+
+	class     *Classroom
+	scenarios []*Scenario
+	mu        sync.Mutex
+	status    []StudentStatus
+	loaded    time.Time
+	loading   chan struct{} // closed when the running load ends, nil if there is none
+}
+
+// NewDashboard creates the dashboard of the classroom students for the scenarios.
+func NewDashboard(c *Classroom, scenarios ...*Scenario) *Dashboard {
+	return &Dashboard{Refresh: DefaultDashboardRefresh, MinReload: DefaultDashboardMinReload, class: c, scenarios: scenarios}
+}
+
+// Status returns the classroom status, it is loaded again if it is older than Refresh or reload is true (and it is older than MinReload).
+// The status is loaded without holding the lock: while a load runs the other calls return the previous status, or wait for it if there is none yet.
+func (d *Dashboard) Status(reload bool) ([]StudentStatus, time.Time) {
+	d.mu.Lock()
+	age := time.Since(d.loaded)
+	if d.status != nil && (d.loading != nil || age <= d.Refresh && (!reload || age < d.MinReload)) {
+		defer d.mu.Unlock()
+		return d.status, d.loaded
+	}
+	if d.loading != nil {
+		loading := d.loading
+		d.mu.Unlock()
+		<-loading
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.status, d.loaded
+	}
+
+	// load it without the lock
+	loading := make(chan struct{})
+	d.loading = loading
+	d.mu.Unlock()
+	status := MClassStatus(d.class, d.scenarios...)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status, d.loaded, d.loading = status, time.Now(), nil
+	close(loading)
+	return d.status, d.loaded
+}
+
+// ServeHTTP serves the page and the JSON status.
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/", "/status.json":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	status, loaded := d.Status(r.URL.Query().Get("reload") == "1")
+	if r.URL.Path == "/status.json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dashboardJSON(d.class.Name(), loaded, status))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := struct {
+		Class     string
+		Salt      string
+		Loaded    string
+		Scenarios []*Scenario
+		Status    []StudentStatus
+	}{d.class.Name(), d.class.Salt(), loaded.Format(time.RFC3339), d.scenarios, status}
+	if err := dashboardPage.Execute(w, data); err != nil {
+		logf(LevelError, "dashboard page failed", "err", err)
+	}
+}
+
+// dashboardJSON is the JSON status: the errors as strings and the amounts as decimal strings.
+func dashboardJSON(class string, loaded time.Time, status []StudentStatus) interface{} {
+	type balanceJSON struct {
+		Asset      string `json:"asset"`
+		Balance    string `json:"balance"`
+		Limit      string `json:"limit,omitempty"`
+		Authorized bool   `json:"authorized"`
+	}
+	type accountJSON struct {
+		Name     string        `json:"name"`
+		Address  string        `json:"address"`
+		Exists   bool          `json:"exists"`
+		Balances []balanceJSON `json:"balances"`
+	}
+	type studentJSON struct {
+		Student  string        `json:"student"`
+		Accounts []accountJSON `json:"accounts"`
+		Grades   []GradeReport `json:"grades"`
+		Error    string        `json:"error,omitempty"`
+	}
+	out := struct {
+		Classroom string        `json:"classroom"`
+		Loaded    time.Time     `json:"loaded"`
+		Students  []studentJSON `json:"students"`
+	}{Classroom: class, Loaded: loaded, Students: []studentJSON{}}
+	for _, ss := range status {
+		sj := studentJSON{Student: ss.Student, Accounts: []accountJSON{}, Grades: ss.Grades}
+		if ss.Err != nil {
+			sj.Error = ss.Err.Error()
+		}
+		for _, as := range ss.Accounts {
+			aj := accountJSON{Name: as.Name, Address: as.Address, Exists: as.Exists, Balances: []balanceJSON{}}
+			for _, b := range as.Balances {
+				bj := balanceJSON{Asset: b.Asset.String(), Balance: b.Balance.String(), Authorized: b.Authorized}
+				if !b.IsNative() {
+					bj.Limit = b.Limit.String()
+				}
+				aj.Balances = append(aj.Balances, bj)
+			}
+			sj.Accounts = append(sj.Accounts, aj)
+		}
+		out.Students = append(out.Students, sj)
+	}
+	return out
+}
+
+// dashboardPage is the dashboard html template.
+var dashboardPage = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Class}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; vertical-align: top; text-align: left; }
+.addr { font-family: monospace; font-size: 12px; color: #666; }
+.missing { color: #b00; }
+.done { color: #080; }
+.not_recorded { color: #888; }
+.wrong_balance { color: #b60; }
+</style>
+</head>
+<body>
+<h1>{{.Class}}</h1>
+<p>Salt <code>{{.Salt}}</code>, loaded {{.Loaded}} (<a href="?reload=1">reload</a>, <a href="status.json">json</a>)</p>
+<table>
+<tr><th>Student</th><th>Accounts</th>{{range .Scenarios}}<th>{{.Name}}</th>{{end}}</tr>
+{{range .Status}}
+<tr>
+<td>{{.Student}}{{if .Err}}<br><span class="missing">{{.Err}}</span>{{end}}</td>
+<td>{{range .Accounts}}
+<b>{{.Name}}</b> <span class="addr">{{.Address}}</span><br>
+{{if .Exists}}{{range .Balances}}{{if .IsNative}}XLM{{else}}{{.Code}}{{end}} {{.Balance}}{{if not .IsNative}} / {{.Limit}}{{if not .Authorized}} (not authorized){{end}}{{end}}<br>{{end}}{{else}}<span class="missing">not created</span><br>{{end}}
+{{end}}</td>
+{{range .Grades}}
+<td>{{.Done}}/{{len .Steps}}<br>{{range .Steps}}<span class="{{.Grade}}" title="{{.Hash}}">{{.Step}} {{.Action}} {{.Name}}: {{.Grade}}</span><br>{{end}}</td>
+{{end}}
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,332 @@
+package colon
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/strkey"
+)
+
+//
+// DISTRIBUTION (AIRDROP)
+// Sends an asset to the addresses of a CSV file (address,amount[,memo]) using batch payments, the results are written to another CSV so an interrupted distribution can be resumed.
+//
+
+// DistRow is a row of the distribution CSV; Line is the CSV line number (starting at 1) and Problem is the validation failure (empty if the row is valid).
+type DistRow struct {
+	PaymentRow
+	Line    int
+	Problem string
+}
+
+// DistSummary is the dry-run summary of a distribution: number of rows, valid and invalid ones and the total amount of the valid rows.
+type DistSummary struct {
+	Rows    int
+	Valid   int
+	Invalid int
+	Total   Amount
+}
+
+// MDistReadCSV reads the distribution CSV file with the columns address, amount and optional memo; the first line is skipped if it is a header (first column "address").
+// All the rows are payments of the asset assCode (empty for XLM); an amount that can not be parsed is left as zero and MDistCheck reports it as invalid.
+func MDistReadCSV(path, assCode string) (rows []DistRow, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(rec[0], "address") {
+			continue
+		}
+		if len(rec) < 2 || len(rec) > 3 {
+			return nil, errors.Errorf("line %d: expected address,amount[,memo]", line)
+		}
+		row := DistRow{PaymentRow: PaymentRow{Dest: rec[0], Asset: assCode}, Line: line}
+		row.Amount, _ = ParseAmount(rec[1])
+		if len(rec) == 3 {
+			row.Memo = rec[2]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// MDistCheck validates every row (address format, amount, account exists, trustline to the asset issued by pairIss and authorized) setting its Problem, and returns the dry-run summary (ErrAmountOverflow if the total does not fit in an Amount).
+func MDistCheck(pairIss Signer, rows []DistRow) (sum DistSummary, err error) {
+	balances := map[string][]hBalance{}
+	for i := range rows {
+		row := &rows[i]
+		row.Problem = ""
+		if _, err := strkey.Decode(strkey.VersionByteAccountID, row.Dest); err != nil {
+			row.Problem = "invalid address"
+		} else if row.Amount <= 0 {
+			row.Problem = "invalid amount"
+		} else if len(row.Memo) > MaxMemoText {
+			row.Problem = "memo longer than " + strconv.Itoa(MaxMemoText) + " bytes"
+		} else {
+			// each account is loaded only once
+			bals, ok := balances[row.Dest]
+			if !ok {
+				if acc, err := loadAccountRaw(row.Dest); err == nil {
+					bals = acc.Balances
+				}
+				balances[row.Dest] = bals
+			}
+			row.Problem = checkTrustline(bals, row.Asset, pairIss.Address())
+		}
+		sum.Rows++
+		if row.Problem != "" {
+			sum.Invalid++
+			continue
+		}
+		sum.Valid++
+		if sum.Total, err = sum.Total.Add(row.Amount); err != nil {
+			return sum, err
+		}
+	}
+	return sum, nil
+}
+
+// checkTrustline returns the problem of sending the asset assCode issued by addrIss to an account with balances bals, empty if there is no problem.
+func checkTrustline(bals []hBalance, assCode, addrIss string) (problem string) {
+	if bals == nil {
+		return string(OpNoDestination)
+	}
+	if assCode == "" {
+		return ""
+	}
+	for _, b := range bals {
+		if b.Code == assCode && b.Issuer == addrIss {
+			if b.IsAuthorized != nil && !*b.IsAuthorized {
+				return string(OpNotAuthorized)
+			}
+			return ""
+		}
+	}
+	return string(OpNoTrust)
+}
+
+// MDistPrintSummary writes to w the dry-run summary and the invalid rows.
+func MDistPrintSummary(w io.Writer, rows []DistRow, sum DistSummary) {
+	for _, r := range rows {
+		if r.Problem != "" {
+			fmt.Fprintln(w, "Line", r.Line, r.Dest, r.Amount, "..invalid", r.Problem)
+		}
+	}
+	fmt.Fprintln(w, "Distribution", "rows", sum.Rows, "valid", sum.Valid, "invalid", sum.Invalid, "total", sum.Total)
+}
+
+// RowSubmitting is the status written to the distribution results before a transaction is submitted, it is replaced by paid or failed when the result is known.
+const RowSubmitting = "submitting"
+
+// MDistribute sends the valid rows (checked with MDistCheck) from pairIss and appends the result of each row to the results CSV (line,address,amount,memo,status,hash,tx_code,op_code,seq).
+// If the results file already exists the rows with status paid are not sent again, so an interrupted distribution is resumed by calling it again with the same files;
+// the rows are sent in groups of MaxOpsPerTrans. Before a transaction is submitted its rows are written with status submitting, the hash and the sequence number,
+// so if the distribution is interrupted while submitting, on resume the transaction is looked up in horizon: if it is in the ledger the rows are paid, if its sequence
+// number has been used or passed it can not be applied any more and the rows are sent again, otherwise it returns an error (the transaction may still be applied).
+func MDistribute(pairIss Signer, rows []DistRow, resultsPath string) (err error) {
+	// read the results of a previous run
+	last, err := distReadResults(resultsPath)
+	if err != nil {
+		return err
+	}
+
+	// open the results file to append
+	f, err := os.OpenFile(resultsPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if st, err := f.Stat(); err == nil && st.Size() == 0 {
+		w.Write([]string{"line", "address", "amount", "memo", "status", "hash", "tx_code", "op_code", "seq"})
+	}
+	write := func(r DistRow, status, hash string, txCode, opCode ResultCode, seq int64) error {
+		w.Write([]string{strconv.Itoa(r.Line), r.Dest, r.Amount.String(), r.Memo, status, hash, string(txCode), string(opCode), strconv.FormatInt(seq, 10)})
+		w.Flush()
+		return w.Error()
+	}
+
+	// check the transactions that were being submitted, and keep the rows not paid
+	paid, err := distCheckSubmitting(pairIss.Address(), last)
+	if err != nil {
+		return err
+	}
+	pending := []DistRow{}
+	for _, r := range rows {
+		if r.Problem != "" {
+			continue
+		}
+		if rec, ok := paid[r.Line]; ok {
+			if last[r.Line].status == RowSubmitting {
+				logf(LevelInfo, "distribution row found in the ledger", "line", r.Line, "hash", rec.hash)
+				if err = write(r, RowPaid, rec.hash, "", "", rec.seq); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		pending = append(pending, r)
+	}
+
+	// send the pending rows in groups, writing them as submitting before each transaction is sent
+	failed, lastErr := 0, error(nil)
+	for len(pending) > 0 {
+		n := MaxOpsPerTrans
+		if n > len(pending) {
+			n = len(pending)
+		}
+		group := pending[:n]
+		pending = pending[n:]
+		payments := make([]PaymentRow, len(group))
+		for i, r := range group {
+			payments[i] = r.PaymentRow
+		}
+		submitted := map[int]bool{}
+		var writeErr error
+		before := func(idx []int, hash string, seq int64) error {
+			for _, i := range idx {
+				if writeErr = write(group[i], RowSubmitting, hash, "", "", seq); writeErr != nil {
+					return writeErr
+				}
+				submitted[i] = true
+			}
+			return nil
+		}
+		report, batchErr := batchPayment(pairIss, payments, before)
+		if writeErr != nil {
+			// without the submitting records a resume could pay twice, so it stops here
+			return writeErr
+		}
+		for i, s := range report {
+			r := group[i]
+			if s.Status != RowPaid {
+				failed++
+			}
+			if s.Status == "" {
+				// not sent, a destination could not be loaded (the error is returned), it is sent on resume
+				continue
+			}
+			if s.Status == RowFailed && submitted[i] && s.TxCode == "" {
+				// the result of the transaction is unknown (e.g. a network error), the row is left as submitting and checked on resume
+				logf(LevelWarn, "distribution transaction result unknown", "line", r.Line, "err", s.Err)
+				continue
+			}
+			if err = write(r, s.Status, s.Hash, s.TxCode, s.OpCode, 0); err != nil {
+				return err
+			}
+		}
+		if batchErr != nil {
+			logf(LevelWarn, "distribution group failed", "err", batchErr)
+			lastErr = batchErr
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d distribution rows not paid, see %s: %v", failed, resultsPath, lastErr)
+	}
+	return lastErr
+}
+
+// distResult is the last result of a line in the distribution results CSV.
+type distResult struct {
+	status string
+	hash   string
+	seq    int64
+}
+
+// distReadResults reads the results CSV (if it exists) and returns the last result of each line.
+func distReadResults(resultsPath string) (last map[int]distResult, err error) {
+	last = map[int]distResult{}
+	f, err := os.Open(resultsPath)
+	if os.IsNotExist(err) {
+		return last, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	recs, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range recs {
+		if len(rec) < 6 {
+			continue
+		}
+		line, err := strconv.Atoi(rec[0])
+		if err != nil {
+			continue
+		}
+		res := distResult{status: rec[4], hash: rec[5]}
+		if len(rec) > 8 {
+			res.seq, _ = strconv.ParseInt(rec[8], 10, 64)
+		}
+		last[line] = res
+	}
+	return last, nil
+}
+
+// distCheckSubmitting returns the lines of last that are paid: the ones with status paid and the ones with status submitting whose transaction is in the ledger.
+// A submitting transaction that is not in the ledger is sent again only if the sequence number of the source account addr has reached its sequence number,
+// otherwise it may still be applied and an error is returned.
+func distCheckSubmitting(addr string, last map[int]distResult) (paid map[int]distResult, err error) {
+	paid = map[int]distResult{}
+	checked := map[string]bool{}
+	for line, res := range last {
+		switch res.status {
+		case RowPaid:
+			paid[line] = res
+		case RowSubmitting:
+			inLedger, ok := checked[res.hash]
+			if !ok {
+				if inLedger, err = distInLedger(addr, res); err != nil {
+					return nil, errors.Errorf("line %d: %v", line, err)
+				}
+				checked[res.hash] = inLedger
+			}
+			if inLedger {
+				paid[line] = res
+			}
+		}
+	}
+	return paid, nil
+}
+
+// distInLedger returns true if the submitting transaction res was applied successfully, false if it failed or can not be applied any more.
+func distInLedger(addr string, res distResult) (inLedger bool, err error) {
+	tx, found, err := loadTransaction(res.hash)
+	if err != nil {
+		return false, err
+	}
+	if found {
+		return tx.Successful == nil || *tx.Successful, nil
+	}
+	acc, err := loadAccount(addr)
+	if err != nil {
+		return false, err
+	}
+	seq, err := strconv.ParseInt(acc.Sequence, 10, 64)
+	if err != nil {
+		return false, err
+	}
+	if res.seq == 0 || seq < res.seq {
+		return false, errors.Errorf("transaction %s is not in the ledger but may still be applied (sequence %d, account sequence %d), try again later", res.hash, res.seq, seq)
+	}
+	return false, nil
+}
@@ -0,0 +1,53 @@
+package colon
+
+//
+// IDEMPOTENT HELPERS
+// The MEnsure functions check the account state first and only send the transaction if it is needed, so a drill can be run again after a partial failure:
+// skipped is true when nothing was sent (and the receipt is empty).
+//
+
+// MEnsureFunded creates and funds the account addr with the friendbot if it does not exist; funded is false if it already existed.
+func MEnsureFunded(addr string) (funded bool, err error) {
+	if err = checkAccount(addr); err == nil {
+		logf(LevelInfo, "account already exists, not funded", "addr", addr)
+		return false, nil
+	} else if !IsAccountNotFound(err) {
+		return false, err
+	}
+	if err = MFund(addr); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MEnsureTrust is MTransTrust but it is skipped if the trustline of pairDis to the asset already has the limit (or, with limit 0, if there is no trustline).
+func MEnsureTrust(pairDis Signer, assCode, addrIss string, limit Amount, checkIss bool) (receipt Receipt, skipped bool, err error) {
+	bals, err := MLoadBalances(pairDis.Address())
+	if err != nil {
+		return receipt, false, err
+	}
+	bal, ok := bals[Asset{Code: assCode, Issuer: addrIss}]
+	if ok && bal.Limit == limit || !ok && limit == 0 {
+		logf(LevelInfo, "trustline already set, skipped", "asset", assCode, "limit", limit, "from", pairDis.Address(), "to", addrIss)
+		return receipt, true, nil
+	}
+	receipt, err = MTransTrust(pairDis, assCode, addrIss, limit, checkIss)
+	return receipt, false, err
+}
+
+// MEnsureAllowTrust is MAllowTrust but it is skipped if the trustline of addr to the asset of pairIss is already authorized (or revoked).
+// The account addr must exist; it is not skipped if the horizon server does not report the authorization of the trustlines.
+func MEnsureAllowTrust(pairIss Signer, assCode, addr string, authorize, checkAddr bool) (receipt Receipt, skipped bool, err error) {
+	acc, err := loadAccountRaw(addr)
+	if err != nil {
+		return receipt, false, err
+	}
+	for _, hb := range acc.Balances {
+		if hb.Type != "native" && hb.Code == assCode && hb.Issuer == pairIss.Address() && hb.IsAuthorized != nil && *hb.IsAuthorized == authorize {
+			logf(LevelInfo, "trustline authorization already set, skipped", "asset", assCode, "from", pairIss.Address(), "to", addr, "authorize", authorize)
+			return receipt, true, nil
+		}
+	}
+	receipt, err = MAllowTrust(pairIss, assCode, addr, authorize, checkAddr)
+	return receipt, false, err
+}
@@ -0,0 +1,375 @@
+package colon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+//
+// FIXTURES
+// The test network is reset periodically and all its accounts disappear. A fixture declares the state that the tests need (accounts, options,
+// trustlines and asset balances) and MProvisionFixture creates it, only sending the transactions of the parts that are missing or different.
+//
+//	{
+//	  "name": "asset",
+//	  "accounts": ["issuing", "distribution"],
+//	  "assets": {"VEF": {"code": "VEF", "issuer": "issuing"}},
+//	  "options": [{"account": "issuing", "setFlags": ["auth_required", "auth_revocable"]}],
+//	  "trustlines": [{"account": "distribution", "asset": "VEF", "limit": "1500", "authorized": true}],
+//	  "balances": [{"account": "distribution", "asset": "VEF", "amount": "100"}]
+//	}
+//
+// The options are applied in two transactions: first the flags and home domain, and after the trustlines and balances the signers, master weight
+// and thresholds (so they do not require more signatures to provision the rest); the transactions are signed only by the account signer.
+// The asset balances are issued by the issuer, or paid back to it if the account has more.
+// MEnsureFixture records the latest ledger and the fixture accounts in a mark file and provisions the fixture again when it detects a reset.
+//
+
+// Fixture is a declared set of accounts, options, trustlines and asset balances.
+type Fixture struct {
+	Name       string                   `json:"name"`
+	Accounts   []string                 `json:"accounts"`
+	Assets     map[string]ScenarioAsset `json:"assets"`
+	Options    []FixtureOptions         `json:"options"`
+	Trustlines []FixtureTrustline       `json:"trustlines"`
+	Balances   []FixtureBalance         `json:"balances"`
+}
+
+// FixtureOptions are the options of an account; the flags are auth_required and auth_revocable, a signer with weight 0 must not be a signer.
+type FixtureOptions struct {
+	Account      string       `json:"account"`
+	SetFlags     []string     `json:"setFlags"`
+	HomeDomain   string       `json:"homeDomain"`
+	MasterWeight *uint32      `json:"masterWeight"`
+	Low          *uint32      `json:"low"`
+	Med          *uint32      `json:"med"`
+	High         *uint32      `json:"high"`
+	Signers      []StepSigner `json:"signers"`
+}
+
+// FixtureTrustline is a trustline of an account, the limit is the maximum if empty; if Authorized is set the issuer authorizes or revokes it.
+type FixtureTrustline struct {
+	Account    string `json:"account"`
+	Asset      string `json:"asset"`
+	Limit      string `json:"limit"`
+	Authorized *bool  `json:"authorized"`
+}
+
+// FixtureBalance is the balance of an asset (not XLM) of an account.
+type FixtureBalance struct {
+	Account string `json:"account"`
+	Asset   string `json:"asset"`
+	Amount  string `json:"amount"`
+}
+
+// LoadFixture reads and validates a fixture file.
+func LoadFixture(path string) (fx *Fixture, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fx = &Fixture{}
+	if err = json.Unmarshal(data, fx); err != nil {
+		return nil, errors.New("fixture " + path + ": " + err.Error())
+	}
+	return fx, fx.Validate()
+}
+
+// Validate checks the accounts, assets, flags and amounts of the fixture.
+func (fx *Fixture) Validate() (err error) {
+	// the accounts and assets are checked as in a scenario
+	sc := &Scenario{Name: fx.Name, Accounts: fx.Accounts, Assets: fx.Assets}
+	if err = sc.Validate(); err != nil {
+		return err
+	}
+	accounts := map[string]bool{}
+	for _, name := range fx.Accounts {
+		accounts[name] = true
+	}
+	fail := func(what, name, msg string) error {
+		return errors.New("fixture " + fx.Name + " " + what + " " + name + ": " + msg)
+	}
+	for _, o := range fx.Options {
+		if !accounts[o.Account] {
+			return fail("options", o.Account, "unknown account")
+		}
+		for _, f := range o.SetFlags {
+			if f != "auth_required" && f != "auth_revocable" {
+				return fail("options", o.Account, "unknown flag \""+f+"\"")
+			}
+		}
+		for _, s := range o.Signers {
+			if !accounts[s.Account] {
+				return fail("options", o.Account, "unknown signer account \""+s.Account+"\"")
+			}
+		}
+	}
+	for _, tl := range fx.Trustlines {
+		if _, ok := fx.Assets[tl.Asset]; !ok || !accounts[tl.Account] {
+			return fail("trustline", tl.Account, "unknown account or asset \""+tl.Asset+"\"")
+		}
+		if tl.Limit != "" {
+			if _, err = ParseAmount(tl.Limit); err != nil {
+				return fail("trustline", tl.Account, err.Error())
+			}
+		}
+	}
+	for _, b := range fx.Balances {
+		if _, ok := fx.Assets[b.Asset]; !ok || !accounts[b.Account] {
+			return fail("balance", b.Account, "unknown account or asset \""+b.Asset+"\"")
+		}
+		if _, err = ParseAmount(b.Amount); err != nil {
+			return fail("balance", b.Account, err.Error())
+		}
+	}
+	return nil
+}
+
+// asset returns the asset of a fixture asset name.
+func (fx *Fixture) asset(signers map[string]Signer, name string) Asset {
+	return Asset{Code: fx.Assets[name].Code, Issuer: signers[fx.Assets[name].Issuer].Address()}
+}
+
+// MProvisionFixture creates the fixture state with the signers of its accounts, only the missing or different parts are sent.
+func MProvisionFixture(fx *Fixture, signers map[string]Signer) (err error) {
+	if err = fx.Validate(); err != nil {
+		return err
+	}
+	for _, name := range fx.Accounts {
+		if signers[name] == nil {
+			return errors.New("no signer for the fixture account " + name)
+		}
+	}
+
+	// accounts
+	for _, name := range fx.Accounts {
+		if _, err = MEnsureFunded(signers[name].Address()); err != nil {
+			return err
+		}
+	}
+	// flags and home domain
+	for _, o := range fx.Options {
+		acc, err := loadAccount(signers[o.Account].Address())
+		if err != nil {
+			return err
+		}
+		opts, flags := map[string]interface{}{}, uint32(0)
+		for _, f := range o.SetFlags {
+			if f == "auth_required" && !acc.Flags.AuthRequired {
+				flags |= uint32(xdr.AccountFlagsAuthRequiredFlag)
+			} else if f == "auth_revocable" && !acc.Flags.AuthRevocable {
+				flags |= uint32(xdr.AccountFlagsAuthRevocableFlag)
+			}
+		}
+		if flags != 0 {
+			opts["SetFlags"] = flags
+		}
+		if o.HomeDomain != "" && o.HomeDomain != acc.HomeDomain {
+			opts["HomeDomain"] = o.HomeDomain
+		}
+		if len(opts) > 0 {
+			if _, err = MSetOptions(signers[o.Account], opts); err != nil {
+				return err
+			}
+		}
+	}
+	// trustlines and authorizations
+	for _, tl := range fx.Trustlines {
+		asset, limit := fx.asset(signers, tl.Asset), Amount(math.MaxInt64)
+		if tl.Limit != "" {
+			limit, _ = ParseAmount(tl.Limit)
+		}
+		if _, _, err = MEnsureTrust(signers[tl.Account], asset.Code, asset.Issuer, limit, false); err != nil {
+			return err
+		}
+		if tl.Authorized != nil {
+			issuer := signers[fx.Assets[tl.Asset].Issuer]
+			if _, _, err = MEnsureAllowTrust(issuer, asset.Code, signers[tl.Account].Address(), *tl.Authorized, false); err != nil {
+				return err
+			}
+		}
+	}
+	// asset balances
+	for _, b := range fx.Balances {
+		asset, holder, issuer := fx.asset(signers, b.Asset), signers[b.Account], signers[fx.Assets[b.Asset].Issuer]
+		want, _ := ParseAmount(b.Amount)
+		bal, err := MBalanceOf(holder.Address(), asset)
+		if err != nil {
+			return err
+		}
+		if bal.Balance < want {
+			err = fixturePay(issuer, holder.Address(), asset, want-bal.Balance)
+		} else if bal.Balance > want {
+			err = fixturePay(holder, issuer.Address(), asset, bal.Balance-want)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// signers, master weight and thresholds
+	for _, o := range fx.Options {
+		if err = fixtureSigners(signers, o); err != nil {
+			return err
+		}
+	}
+	logf(LevelInfo, "fixture provisioned", "fixture", fx.Name)
+	return nil
+}
+
+// fixturePay sends amt of the asset from source to addrDest.
+func fixturePay(source Signer, addrDest string, asset Asset, amt Amount) error {
+	tb, err := MTrans(source.Address(), build.Payment(build.Destination{addrDest}, build.CreditAmount{asset.Code, asset.Issuer, amt.String()}))
+	if err != nil {
+		return err
+	}
+	logf(LevelInfo, "fixture payment", "asset", asset, "amount", amt, "from", source.Address(), "to", addrDest)
+	_, err = MSignSubmit(source, tb)
+	return err
+}
+
+// fixtureSigners sets the signers that have another weight, then the master weight and thresholds if they are different.
+func fixtureSigners(signers map[string]Signer, o FixtureOptions) error {
+	addr := signers[o.Account].Address()
+	acc, err := loadAccount(addr)
+	if err != nil {
+		return err
+	}
+	weights := map[string]uint32{}
+	for _, s := range acc.Signers {
+		weights[signerKey(s)] = uint32(s.Weight)
+	}
+	for _, s := range o.Signers {
+		signerAddr := signers[s.Account].Address()
+		if weights[signerAddr] == s.Weight {
+			continue
+		}
+		pk, err := strkey.Decode(strkey.VersionByteAccountID, signerAddr)
+		if err != nil {
+			return err
+		}
+		var key [32]byte
+		copy(key[:], pk)
+		opts := map[string]interface{}{"Signer": []interface{}{int32(xdr.SignerKeyTypeSignerKeyTypeEd25519), key, s.Weight}}
+		if _, err = MSetOptions(signers[o.Account], opts); err != nil {
+			return err
+		}
+	}
+
+	opts := map[string]interface{}{}
+	for _, v := range []struct {
+		name string
+		want *uint32
+		got  uint32
+	}{{"MasterWeight", o.MasterWeight, weights[addr]}, {"LowThreshold", o.Low, uint32(acc.Thresholds.LowThreshold)},
+		{"MedThreshold", o.Med, uint32(acc.Thresholds.MedThreshold)}, {"HighThreshold", o.High, uint32(acc.Thresholds.HighThreshold)}} {
+		if v.want != nil && *v.want != v.got {
+			opts[v.name] = *v.want
+		}
+	}
+	if len(opts) == 0 {
+		return nil
+	}
+	_, err = MSetOptions(signers[o.Account], opts)
+	return err
+}
+
+// NetworkMark is the state of the network recorded after provisioning a fixture: the network passphrase, the latest ledger and the fixture accounts.
+type NetworkMark struct {
+	Passphrase string   `json:"passphrase"`
+	Ledger     int32    `json:"ledger"`
+	Accounts   []string `json:"accounts"`
+}
+
+// MLatestLedger gets the sequence of the latest ledger from the horizon server.
+func MLatestLedger() (seq int32, err error) {
+	rec, err := latestLedger()
+	return rec.Sequence, err
+}
+
+// MDetectReset returns true if the network has been reset since the mark: the latest ledger sequence is lower than the marked one or a marked account does not exist.
+// The reason describes what was detected.
+func MDetectReset(mark NetworkMark) (reset bool, reason string, err error) {
+	seq, err := MLatestLedger()
+	if err != nil {
+		return false, "", err
+	}
+	if seq < mark.Ledger {
+		return true, "ledger sequence dropped from " + strconv.Itoa(int(mark.Ledger)) + " to " + strconv.Itoa(int(seq)), nil
+	}
+	for _, addr := range mark.Accounts {
+		if err = checkAccount(addr); IsAccountNotFound(err) {
+			return true, "account " + addr + " not found", nil
+		} else if err != nil {
+			return false, "", err
+		}
+	}
+	return false, "", nil
+}
+
+// MEnsureFixture provisions the fixture if there is no mark file (or it is of another network or accounts) or if a reset is detected since the mark,
+// then it writes the mark; provisioned is true if the fixture was provisioned.
+func MEnsureFixture(fx *Fixture, signers map[string]Signer, markPath string) (provisioned bool, err error) {
+	mark := NetworkMark{Passphrase: CurrentNetwork().Passphrase}
+	for _, name := range fx.Accounts {
+		if signers[name] == nil {
+			return false, errors.New("no signer for the fixture account " + name)
+		}
+		mark.Accounts = append(mark.Accounts, signers[name].Address())
+	}
+
+	// the previous mark, if it is of this network and accounts
+	reason := "no mark"
+	data, err := ioutil.ReadFile(markPath)
+	if err == nil {
+		var prev NetworkMark
+		if err = json.Unmarshal(data, &prev); err != nil {
+			return false, errors.New("mark " + markPath + ": " + err.Error())
+		}
+		reason = "mark of another network or accounts"
+		if prev.Passphrase == mark.Passphrase && sameStrings(prev.Accounts, mark.Accounts) {
+			reset, why, err := MDetectReset(prev)
+			if err != nil {
+				return false, err
+			}
+			if !reset {
+				return false, nil
+			}
+			reason = why
+		}
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	logf(LevelWarn, "provisioning fixture", "fixture", fx.Name, "reason", reason)
+	if err = MProvisionFixture(fx, signers); err != nil {
+		return false, err
+	}
+	if mark.Ledger, err = MLatestLedger(); err != nil {
+		return true, err
+	}
+	if data, err = json.MarshalIndent(mark, "", "  "); err != nil {
+		return true, err
+	}
+	return true, ioutil.WriteFile(markPath, data, 0644)
+}
+
+// sameStrings returns true if both lists have the same strings in the same order.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
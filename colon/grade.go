@@ -0,0 +1,295 @@
+package colon
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/go-errors/errors"
+)
+
+//
+// GRADER
+// The accounts of a drill are derived from the learner base seed (see MScenarioAddresses), so the instructor can check which steps the learner completed.
+// MGrade loads the operations history of the scenario accounts and matches each step with an operation (or for multi with the operations of one transaction).
+// Horizon only records the successful transactions, so the steps that are expected to fail are reported as not recorded instead of done or missing.
+// The expected balances are checked with the current balances, so only the last expectation of each account and asset is checked (the later steps change the earlier ones).
+//
+
+// Step grades.
+const (
+	GradeDone        = "done"
+	GradeMissing     = "missing"
+	GradeNotRecorded = "not_recorded"
+	GradeBalance     = "wrong_balance"
+)
+
+// GradeAccount is a scenario account of the learner, Exists is false if it has not been created (or it was merged).
+type GradeAccount struct {
+	Name    string
+	Address string
+	Exists  bool
+}
+
+// StepGrade is the grade of a step, Hash is the transaction that completed it and Problems the expected balances that are not the current ones.
+type StepGrade struct {
+	Step     int
+	Name     string
+	Action   string
+	Grade    string
+	Hash     string
+	Problems []string
+}
+
+// GradeReport is the grade of a scenario: the learner accounts, the grade of each step and the number of done and missing steps (a wrong balance is missing).
+type GradeReport struct {
+	Scenario string
+	Accounts []GradeAccount
+	Steps    []StepGrade
+	Done     int
+	Missing  int
+}
+
+// MScenarioAddresses returns the addresses of the scenario accounts derived with kd (the package KeyDeriver if nil) and namespace, see MScenarioSigners.
+func MScenarioAddresses(sc *Scenario, kd *KeyDeriver, namespace string) (addrs map[string]string, err error) {
+	signers, err := MScenarioSigners(sc, kd, namespace)
+	if err != nil {
+		return nil, err
+	}
+	addrs = map[string]string{}
+	for name, s := range signers {
+		addrs[name] = s.Address()
+	}
+	return addrs, nil
+}
+
+// MGrade checks which steps of the scenario were completed by the accounts addrs (account name -> address, e.g. from MScenarioAddresses).
+// Each operation of the history completes at most one step, so a step repeated in the scenario must also be repeated by the learner.
+func MGrade(sc *Scenario, addrs map[string]string) (report GradeReport, err error) {
+	if err = sc.Validate(); err != nil {
+		return report, err
+	}
+	report.Scenario = sc.Name
+
+	// load the history of the accounts that exist, an operation with several scenario accounts is only kept once
+	ops, seen := []hOperation{}, map[string]bool{}
+	for _, name := range sc.Accounts {
+		addr := addrs[name]
+		if addr == "" {
+			return report, errors.New("no address for the scenario account " + name)
+		}
+		err = checkAccount(addr)
+		if err != nil && !IsAccountNotFound(err) {
+			return report, err
+		}
+		report.Accounts = append(report.Accounts, GradeAccount{Name: name, Address: addr, Exists: err == nil})
+		if err != nil {
+			continue
+		}
+		accOps, err := loadOperations(addr)
+		if err != nil {
+			return report, err
+		}
+		for _, op := range accOps {
+			if !seen[op.ID] {
+				seen[op.ID] = true
+				ops = append(ops, op)
+			}
+		}
+	}
+
+	// the last step that expects the balance of each account and asset
+	lastBalance := map[string]int{}
+	for i, s := range sc.Steps {
+		for name, bals := range s.Expect.Balances {
+			for key := range bals {
+				lastBalance[name+" "+key] = i
+			}
+		}
+	}
+
+	// match the steps in order with the operations not used yet
+	used, balances := map[string]bool{}, map[string]map[Asset]Balance{}
+	for i, s := range sc.Steps {
+		g := StepGrade{Step: i, Name: s.Name, Action: s.Action, Grade: GradeMissing}
+		if s.Expect.Tx != "" && s.Expect.Tx != TxSuccess {
+			g.Grade = GradeNotRecorded
+		} else if s.Action == ActionMulti {
+			g.Hash = sc.gradeMulti(addrs, s, ops, used)
+		} else {
+			for _, op := range ops {
+				if !used[op.ID] && sc.gradeOp(addrs, s, op) {
+					used[op.ID], g.Hash = true, op.TransactionHash
+					break
+				}
+			}
+		}
+		if g.Hash != "" {
+			g.Grade = GradeDone
+			if g.Problems, err = sc.gradeBalances(addrs, i, s, lastBalance, balances); err != nil {
+				return report, err
+			}
+			if len(g.Problems) > 0 {
+				g.Grade = GradeBalance
+			}
+		}
+		switch g.Grade {
+		case GradeDone:
+			report.Done++
+		case GradeMissing, GradeBalance:
+			report.Missing++
+		}
+		report.Steps = append(report.Steps, g)
+	}
+	return report, nil
+}
+
+// MGradePrint prints the grade of each step and the totals.
+func MGradePrint(w io.Writer, report GradeReport) {
+	for _, a := range report.Accounts {
+		if !a.Exists {
+			fmt.Fprintln(w, "Account", a.Name, a.Address, "..not found")
+		}
+	}
+	for _, g := range report.Steps {
+		fmt.Fprintln(w, "Step", g.Step, g.Action, g.Name, "..."+g.Grade, g.Hash)
+		for _, p := range g.Problems {
+			fmt.Fprintln(w, "   ", p)
+		}
+	}
+	fmt.Fprintln(w, "Grade", report.Scenario, "done", report.Done, "missing", report.Missing, "steps", len(report.Steps))
+}
+
+// gradeBalances returns the expected balances of the step i that are not the current ones, only the last expectation of each account and asset is checked.
+// The balances loaded are kept in balances by address.
+func (sc *Scenario) gradeBalances(addrs map[string]string, i int, s Step, lastBalance map[string]int, balances map[string]map[Asset]Balance) (problems []string, err error) {
+	for name, bals := range s.Expect.Balances {
+		for key, amt := range bals {
+			if lastBalance[name+" "+key] != i {
+				continue
+			}
+			addr := addrs[name]
+			if balances[addr] == nil {
+				if balances[addr], err = MLoadBalances(addr); err != nil && !IsAccountNotFound(err) {
+					return nil, err
+				}
+			}
+			want, _ := ParseAmount(amt)
+			asset := XLM
+			if key != "XLM" {
+				asset = Asset{Code: sc.Assets[key].Code, Issuer: addrs[sc.Assets[key].Issuer]}
+			}
+			if bal, ok := balances[addr][asset]; !ok {
+				problems = append(problems, "balance of "+name+" "+key+" not found, expected "+want.String())
+			} else if bal.Balance != want {
+				problems = append(problems, "balance of "+name+" "+key+" "+bal.Balance.String()+", expected "+want.String())
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// gradeMulti returns the hash of the first transaction with unused operations that match all the ops of the step (and marks them as used), or "" if there is none.
+func (sc *Scenario) gradeMulti(addrs map[string]string, s Step, ops []hOperation, used map[string]bool) string {
+	tried := map[string]bool{}
+	for _, first := range ops {
+		hash := first.TransactionHash
+		if used[first.ID] || tried[hash] {
+			continue
+		}
+		tried[hash] = true
+		matched := []string{}
+		for _, sub := range s.Ops {
+			for _, op := range ops {
+				if op.TransactionHash == hash && !used[op.ID] && !containsString(matched, op.ID) && sc.gradeOp(addrs, sub, op) {
+					matched = append(matched, op.ID)
+					break
+				}
+			}
+		}
+		if len(matched) == len(s.Ops) {
+			for _, id := range matched {
+				used[id] = true
+			}
+			return hash
+		}
+	}
+	return ""
+}
+
+// gradeOp returns true if the operation op of the history is the operation of the step s.
+func (sc *Scenario) gradeOp(addrs map[string]string, s Step, op hOperation) bool {
+	addr := addrs[s.Account]
+	switch s.Action {
+	case ActionFund:
+		return op.Type == "create_account" && op.Account == addr
+	case ActionCreate:
+		return op.Type == "create_account" && op.Funder == addr && op.Account == addrs[s.To] && sameAmount(op.StartingBalance, s.Amount)
+	case ActionPay:
+		return op.Type == "payment" && op.From == addr && op.To == addrs[s.To] && sc.sameAsset(addrs, s.Asset, op) && sameAmount(op.Amount, s.Amount)
+	case ActionTrust:
+		return op.Type == "change_trust" && op.Trustor == addr && sc.sameAsset(addrs, s.Asset, op) && (s.Amount == "" || sameAmount(op.Limit, s.Amount))
+	case ActionAllow:
+		authorize := s.Authorize == nil || *s.Authorize
+		return op.Type == "allow_trust" && op.Trustee == addr && op.Trustor == addrs[s.Trustor] && op.AssetCode == sc.Assets[s.Asset].Code && op.Authorize == authorize
+	case ActionSetOptions:
+		if op.Type != "set_options" || op.SourceAccount != addr {
+			return false
+		}
+		for _, f := range s.SetFlags {
+			if !containsString(op.SetFlagsS, f) {
+				return false
+			}
+		}
+		for _, f := range s.ClearFlags {
+			if !containsString(op.ClearFlagsS, f) {
+				return false
+			}
+		}
+		if !sameWeight(op.MasterKeyWeight, s.MasterWeight) || !sameWeight(op.LowThreshold, s.Low) || !sameWeight(op.MedThreshold, s.Med) || !sameWeight(op.HighThreshold, s.High) {
+			return false
+		}
+		if s.HomeDomain != "" && op.HomeDomain != s.HomeDomain {
+			return false
+		}
+		if s.Signer != nil {
+			return op.SignerKey == addrs[s.Signer.Account] && sameWeight(op.SignerWeight, &s.Signer.Weight)
+		}
+		return true
+	}
+	return false
+}
+
+// sameAsset returns true if the asset of the operation is the scenario asset (XLM if empty).
+func (sc *Scenario) sameAsset(addrs map[string]string, asset string, op hOperation) bool {
+	if asset == "" || asset == "XLM" {
+		return op.AssetType == "native"
+	}
+	return op.AssetCode == sc.Assets[asset].Code && op.AssetIssuer == addrs[sc.Assets[asset].Issuer]
+}
+
+// sameAmount returns true if the horizon amount (e.g. "100.0000000") is the scenario amount (e.g. "100").
+func sameAmount(got, want string) bool {
+	a, err := ParseAmount(got)
+	if err != nil {
+		return false
+	}
+	b, err := ParseAmount(want)
+	return err == nil && a == b
+}
+
+// sameWeight returns true if the weight or threshold want is not set or it is the one set by the operation.
+func sameWeight(got, want *uint32) bool {
+	return want == nil || got != nil && *got == *want
+}
+
+// containsString returns true if list contains s.
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package colon
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/stellar/go/clients/horizon"
+)
+
+//
+// HORIZON RESOURCES
+// Some horizon resources (or some fields of them) are not decoded by the horizon client, these functions get them directly from the horizon server.
+//
+
+// hBalance is an account balance with the trustline fields that horizon.Balance does not decode.
+// IsAuthorized is nil when the horizon server does not report it (native balance or old servers).
+type hBalance struct {
+	horizon.Balance
+	BuyingLiabilities  string `json:"buying_liabilities"`
+	SellingLiabilities string `json:"selling_liabilities"`
+	IsAuthorized       *bool  `json:"is_authorized"`
+}
+
+// hGet gets the horizon resource path (e.g. "/accounts/GABC...") and decodes the json response into v.
+func hGet(path string, v interface{}) (err error) {
+	resp, err := http.Get(hClient().URL + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &hStatusError{Path: path, Status: resp.StatusCode}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// hStatusError is returned by hGet when the horizon server does not answer 200 OK.
+type hStatusError struct {
+	Path   string
+	Status int
+}
+
+// Error returns the error message.
+func (e *hStatusError) Error() string {
+	return "horizon " + e.Path + " status " + strconv.Itoa(e.Status)
+}
+
+// hAccount is an account with the fields needed to compute the balances.
+type hAccount struct {
+	SubentryCount int32      `json:"subentry_count"`
+	Balances      []hBalance `json:"balances"`
+}
+
+// loadAccountRaw gets the account addr with the trustline fields of the balances.
+func loadAccountRaw(addr string) (acc hAccount, err error) {
+	err = hGet("/accounts/"+addr, &acc)
+	return acc, err
+}
+
+// hOperation is an operation of the account history with the fields of the operation types used in the drills.
+type hOperation struct {
+	ID              string `json:"id"`
+	PagingToken     string `json:"paging_token"`
+	Type            string `json:"type"`
+	SourceAccount   string `json:"source_account"`
+	TransactionHash string `json:"transaction_hash"`
+	// create_account
+	Funder          string `json:"funder"`
+	Account         string `json:"account"`
+	StartingBalance string `json:"starting_balance"`
+	// payment (and the asset of change_trust and allow_trust)
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Amount      string `json:"amount"`
+	AssetType   string `json:"asset_type"`
+	AssetCode   string `json:"asset_code"`
+	AssetIssuer string `json:"asset_issuer"`
+	// change_trust and allow_trust
+	Trustor   string `json:"trustor"`
+	Trustee   string `json:"trustee"`
+	Limit     string `json:"limit"`
+	Authorize bool   `json:"authorize"`
+	// set_options
+	SignerKey       string   `json:"signer_key"`
+	SignerWeight    *uint32  `json:"signer_weight"`
+	MasterKeyWeight *uint32  `json:"master_key_weight"`
+	LowThreshold    *uint32  `json:"low_threshold"`
+	MedThreshold    *uint32  `json:"med_threshold"`
+	HighThreshold   *uint32  `json:"high_threshold"`
+	HomeDomain      string   `json:"home_domain"`
+	SetFlagsS       []string `json:"set_flags_s"`
+	ClearFlagsS     []string `json:"clear_flags_s"`
+}
+
+// loadOperations gets all the operations of the account addr history (oldest first), following the horizon pages.
+func loadOperations(addr string) (ops []hOperation, err error) {
+	const limit = 200
+	cursor := ""
+	for {
+		var page struct {
+			Embedded struct {
+				Records []hOperation `json:"records"`
+			} `json:"_embedded"`
+		}
+		if err = hGet("/accounts/"+addr+"/operations?order=asc&limit="+strconv.Itoa(limit)+"&cursor="+cursor, &page); err != nil {
+			return nil, err
+		}
+		ops = append(ops, page.Embedded.Records...)
+		if len(page.Embedded.Records) < limit {
+			return ops, nil
+		}
+		cursor = page.Embedded.Records[len(page.Embedded.Records)-1].PagingToken
+	}
+}
+
+// hTransaction is a transaction of the ledger; Successful is nil when the horizon server does not report it (old servers only keep the successful ones).
+type hTransaction struct {
+	Hash       string `json:"hash"`
+	Ledger     int32  `json:"ledger"`
+	Successful *bool  `json:"successful"`
+}
+
+// loadTransaction gets the transaction hash from the horizon server, found is false if it is not in the ledger.
+func loadTransaction(hash string) (tx hTransaction, found bool, err error) {
+	err = hGet("/transactions/"+hash, &tx)
+	if se, ok := err.(*hStatusError); ok && se.Status == http.StatusNotFound {
+		return tx, false, nil
+	}
+	return tx, err == nil, err
+}
+
+// hOfferAsset is the selling or buying asset of an offer.
+type hOfferAsset struct {
+	AssetType   string `json:"asset_type"`
+	AssetCode   string `json:"asset_code"`
+	AssetIssuer string `json:"asset_issuer"`
+}
+
+// hOffer is an open offer of an account.
+type hOffer struct {
+	ID      uint64      `json:"id"`
+	Selling hOfferAsset `json:"selling"`
+	Buying  hOfferAsset `json:"buying"`
+	Amount  string      `json:"amount"`
+	Price   string      `json:"price"`
+}
+
+// loadOffers gets the open offers of the account addr, following the horizon pages.
+func loadOffers(addr string) (offers []hOffer, err error) {
+	const limit = 200
+	cursor := ""
+	for {
+		var page struct {
+			Embedded struct {
+				Records []struct {
+					hOffer
+					PagingToken string `json:"paging_token"`
+				} `json:"records"`
+			} `json:"_embedded"`
+		}
+		if err = hGet("/accounts/"+addr+"/offers?limit="+strconv.Itoa(limit)+"&cursor="+cursor, &page); err != nil {
+			return nil, err
+		}
+		for _, r := range page.Embedded.Records {
+			offers = append(offers, r.hOffer)
+		}
+		if len(page.Embedded.Records) < limit {
+			return offers, nil
+		}
+		cursor = page.Embedded.Records[len(page.Embedded.Records)-1].PagingToken
+	}
+}
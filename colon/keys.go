@@ -0,0 +1,182 @@
+package colon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/keypair"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+//
+// DETERMINISTIC KEYS
+// A KeyDeriver generates always the same keypair for the same base seed and account name, so the drill accounts can be regenerated at any moment.
+// Init and DeterministicKeypair use a package KeyDeriver; to derive keys for several base seeds at once (e.g. two classrooms) create a KeyDeriver for each one.
+//
+// There are two derivation versions:
+//  - DerivationLegacy copies the base seed and the account name into the raw seed, the keys can be guessed from the human strings; it is kept for the existing drill accounts
+//  - DerivationV1 stretches the base seed with scrypt (with a salt) into a master key and derives each account seed with HKDF-SHA256, the seeds are uniformly random
+//
+// The account names can be namespaced with "/" (e.g. "course/student042/distributor"), each segment has letters, digits, "-", "_" or ".".
+// With DerivationLegacy the names of up to 8 bytes keep the legacy raw seed and are not validated (any bytes, as the existing drill accounts),
+// the other names are validated and derived with HMAC-SHA256(baseSeed, name).
+//
+
+// MaxAccountNameLen is the maximum length of an account name (including the namespaces).
+const MaxAccountNameLen = 128
+
+// AccountNameError is returned when an account name is not valid, Reason describes the problem.
+type AccountNameError struct {
+	Name   string
+	Reason string
+}
+
+// Error returns the error message.
+func (e *AccountNameError) Error() string {
+	return "invalid account name \"" + e.Name + "\": " + e.Reason
+}
+
+// ValidateAccountName returns an AccountNameError if name is not a valid (optionally namespaced) account name.
+func ValidateAccountName(name string) (err error) {
+	if name == "" {
+		return &AccountNameError{name, "empty name"}
+	}
+	if len(name) > MaxAccountNameLen {
+		return &AccountNameError{name, "longer than " + strconv.Itoa(MaxAccountNameLen) + " characters"}
+	}
+	for _, seg := range strings.Split(name, "/") {
+		if seg == "" {
+			return &AccountNameError{name, "empty namespace segment"}
+		}
+		for _, c := range seg {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+				return &AccountNameError{name, "invalid character " + strconv.QuoteRune(c)}
+			}
+		}
+	}
+	return nil
+}
+
+// DerivationVersion is the scheme used by a KeyDeriver to derive the keypairs.
+type DerivationVersion int
+
+// Derivation versions.
+const (
+	DerivationLegacy DerivationVersion = 0
+	DerivationV1     DerivationVersion = 1
+)
+
+// scrypt parameters of DerivationV1 (N=2^15, r=8, p=1 takes about 100ms and 32MB, done once per KeyDeriver).
+const (
+	v1ScryptN = 1 << 15
+	v1ScryptR = 8
+	v1ScryptP = 1
+)
+
+// KeyDeriver generates deterministic keypairs from a base seed; it is not modified after created so it is safe for concurrent use.
+type KeyDeriver struct {
+	version DerivationVersion
+	// baseSeed is the base seed used to generate deterministic keypairs (legacy), the lenght is 24bytes
+	baseSeed string
+	// master is the key derived from the base seed and the salt (v1)
+	master []byte
+}
+
+// NewKeyDeriver creates a KeyDeriver with the base seed, its length must be between 8 and 24 (shorter seeds are padded with spaces).
+func NewKeyDeriver(seed string) (kd *KeyDeriver, err error) {
+	l := len(seed)
+	if l < 8 {
+		return nil, errors.New("Minimum seed length 8")
+	} else if l < 24 {
+		seed = seed + strings.Repeat(" ", 24-l)
+	} else if l > 24 {
+		return nil, errors.New("Maximum seed length 24")
+	}
+	return &KeyDeriver{version: DerivationLegacy, baseSeed: seed}, nil
+}
+
+// NewKeyDeriverV1 creates a KeyDeriver that uses DerivationV1 with the base seed (minimum length 8) and the salt.
+// The salt should be different for each deployment (e.g. the course name and year), the same base seed with another salt derives other keypairs.
+func NewKeyDeriverV1(seed, salt string) (kd *KeyDeriver, err error) {
+	if len(seed) < 8 {
+		return nil, errors.New("Minimum seed length 8")
+	}
+	if salt == "" {
+		return nil, errors.New("empty salt")
+	}
+	master, err := scrypt.Key([]byte(seed), []byte("colongo/v1/"+salt), v1ScryptN, v1ScryptR, v1ScryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyDeriver{version: DerivationV1, master: master}, nil
+}
+
+// Version returns the derivation version of the KeyDeriver.
+func (kd *KeyDeriver) Version() DerivationVersion {
+	return kd.version
+}
+
+// Derive generates the keypair (seed+address) of the account name accName, if the name is not valid (see ValidateAccountName) it returns an AccountNameError.
+//   - DerivationLegacy: non empty names of up to 8 bytes use baseSeed+accName as the seed (the existing drill accounts, any bytes are accepted),
+//     the others HMAC-SHA256(baseSeed, accName)
+//   - DerivationV1: the seed is HKDF-SHA256(master, accName)
+func (kd *KeyDeriver) Derive(accName string) (pair *keypair.Full, err error) {
+	byteSeed := [32]byte{}
+	if kd.version == DerivationLegacy && accName != "" && len(accName) <= 8 {
+		// generate a [32]byte seed with the base seed and the name bytes, as DeterministicKeypair always did
+		copy(byteSeed[:], kd.baseSeed+accName)
+		return keypair.FromRawSeed(byteSeed)
+	}
+	if err = ValidateAccountName(accName); err != nil {
+		return nil, err
+	}
+	switch kd.version {
+	case DerivationV1:
+		r := hkdf.New(sha256.New, kd.master, nil, []byte("colongo/v1/account/"+accName))
+		if _, err = io.ReadFull(r, byteSeed[:]); err != nil {
+			return nil, err
+		}
+	default:
+		mac := hmac.New(sha256.New, []byte(kd.baseSeed))
+		mac.Write([]byte("colongo/legacy/account/" + accName))
+		copy(byteSeed[:], mac.Sum(nil))
+	}
+	// generate the keypair from the seed
+	return keypair.FromRawSeed(byteSeed)
+}
+
+// DeterministicKeypair generates a keypair (seed+address) for the account name accName, it returns nil if accName is not valid (use Derive to get the error).
+func (kd *KeyDeriver) DeterministicKeypair(accName string) (pair *keypair.Full) {
+	pair, err := kd.Derive(accName)
+	if err != nil {
+		logf(LevelWarn, "DeterministicKeypair failed", "err", err)
+		return nil
+	}
+	return pair
+}
+
+// defaultDeriver is the KeyDeriver used by Init and DeterministicKeypair, it has an empty base seed until Init is called.
+var (
+	defaultDeriver   = &KeyDeriver{}
+	defaultDeriverMu sync.RWMutex
+)
+
+// SetDefaultKeyDeriver sets the package KeyDeriver used by DeterministicKeypair (e.g. a DerivationV1 one).
+func SetDefaultKeyDeriver(kd *KeyDeriver) {
+	defaultDeriverMu.Lock()
+	defaultDeriver = kd
+	defaultDeriverMu.Unlock()
+}
+
+// DefaultKeyDeriver returns the package KeyDeriver set by Init or SetDefaultKeyDeriver.
+func DefaultKeyDeriver() *KeyDeriver {
+	defaultDeriverMu.RLock()
+	defer defaultDeriverMu.RUnlock()
+	return defaultDeriver
+}
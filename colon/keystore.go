@@ -0,0 +1,341 @@
+package colon
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+//
+// KEYSTORE
+// An encrypted file with seeds, so the seeds do not have to be passed as strings or written in the source code.
+// The file is JSON: the scrypt parameters and salt to derive the key from the password, and the entries with the label and address in cleartext
+// and the seed encrypted with XChaCha20-Poly1305 (the label and address are authenticated, so they cannot be changed or swapped between entries).
+// The signing helpers MKey, MKeySigner, MSignLabels and MSignSubmitLabel get the keys by label from the package keystore (see SetDefaultKeystore).
+//
+
+// keystoreVersion is the version of the keystore file format.
+const keystoreVersion = 1
+
+// keystoreCheck is encrypted in the file to detect a wrong password even if the keystore has no entries.
+const keystoreCheck = "colongo keystore"
+
+// ErrWrongPassword is returned when the keystore password is not the one used to create it (or the file has been modified).
+var ErrWrongPassword = errors.New("wrong keystore password")
+
+// keystoreFile is the keystore file content; the []byte fields are base64 in the JSON.
+type keystoreFile struct {
+	Version int             `json:"version"`
+	KDF     keystoreKDF     `json:"kdf"`
+	Check   []byte          `json:"check"`
+	Entries []keystoreEntry `json:"entries"`
+}
+
+// keystoreKDF are the scrypt parameters used to derive the key from the password.
+type keystoreKDF struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt []byte `json:"salt"`
+}
+
+// Limits of the scrypt parameters read from a keystore file, so a corrupted or crafted file can not demand gigabytes of memory or hours of CPU.
+const (
+	maxKeystoreN   = 1 << 20
+	maxKeystoreR   = 32
+	maxKeystoreP   = 16
+	maxKeystoreMem = 256 << 20
+)
+
+// validate checks that N is a power of two and the parameters are within the limits (the memory used is 128*N*r bytes).
+func (kdf keystoreKDF) validate() error {
+	if kdf.N < 2 || kdf.N > maxKeystoreN || kdf.N&(kdf.N-1) != 0 {
+		return errors.Errorf("invalid scrypt N %d, it must be a power of two up to %d", kdf.N, maxKeystoreN)
+	}
+	if kdf.R < 1 || kdf.R > maxKeystoreR {
+		return errors.Errorf("invalid scrypt r %d, maximum %d", kdf.R, maxKeystoreR)
+	}
+	if kdf.P < 1 || kdf.P > maxKeystoreP {
+		return errors.Errorf("invalid scrypt p %d, maximum %d", kdf.P, maxKeystoreP)
+	}
+	if 128*kdf.N*kdf.R > maxKeystoreMem {
+		return errors.Errorf("invalid scrypt parameters N %d r %d, they need more than %d MB", kdf.N, kdf.R, maxKeystoreMem>>20)
+	}
+	if len(kdf.Salt) == 0 {
+		return errors.New("empty scrypt salt")
+	}
+	return nil
+}
+
+// keystoreEntry is a keystore entry, Box is the nonce followed by the encrypted seed.
+type keystoreEntry struct {
+	Label   string `json:"label"`
+	Address string `json:"address"`
+	Box     []byte `json:"box"`
+}
+
+// KeystoreEntry is the cleartext part of a keystore entry.
+type KeystoreEntry struct {
+	Label   string
+	Address string
+}
+
+// Keystore is an open keystore file, every change is written to the file; it is safe for concurrent use.
+type Keystore struct {
+	mu   sync.Mutex
+	path string
+	key  []byte
+	file keystoreFile
+}
+
+// CreateKeystore creates a new empty keystore file protected with password; it fails if the file already exists.
+func CreateKeystore(path, password string) (ks *Keystore, err error) {
+	if password == "" {
+		return nil, errors.New("empty keystore password")
+	}
+	if _, err = os.Stat(path); err == nil {
+		return nil, errors.New("keystore " + path + " already exists")
+	}
+	ks = &Keystore{path: path, file: keystoreFile{Version: keystoreVersion, Entries: []keystoreEntry{}}}
+	ks.file.KDF = keystoreKDF{N: v1ScryptN, R: v1ScryptR, P: v1ScryptP, Salt: make([]byte, 16)}
+	if _, err = rand.Read(ks.file.KDF.Salt); err != nil {
+		return nil, err
+	}
+	if ks.key, err = ks.file.KDF.key(password); err != nil {
+		return nil, err
+	}
+	if ks.file.Check, err = ks.seal([]byte(keystoreCheck), nil); err != nil {
+		return nil, err
+	}
+	return ks, ks.save()
+}
+
+// OpenKeystore opens the keystore file, it returns ErrWrongPassword if password is not the one used to create it
+// and an error if the scrypt parameters of the file are out of the limits.
+func OpenKeystore(path, password string) (ks *Keystore, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ks = &Keystore{path: path}
+	if err = json.Unmarshal(data, &ks.file); err != nil {
+		return nil, errors.New("keystore " + path + ": " + err.Error())
+	}
+	if ks.file.Version != keystoreVersion {
+		return nil, errors.Errorf("keystore %s: unsupported version %d", path, ks.file.Version)
+	}
+	if err = ks.file.KDF.validate(); err != nil {
+		return nil, errors.New("keystore " + path + ": " + err.Error())
+	}
+	if ks.key, err = ks.file.KDF.key(password); err != nil {
+		return nil, err
+	}
+	if check, err := ks.open(ks.file.Check, nil); err != nil || string(check) != keystoreCheck {
+		return nil, ErrWrongPassword
+	}
+	return ks, nil
+}
+
+// key derives the encryption key from the password.
+func (kdf keystoreKDF) key(password string) ([]byte, error) {
+	return scrypt.Key([]byte(password), kdf.Salt, kdf.N, kdf.R, kdf.P, chacha20poly1305.KeySize)
+}
+
+// seal encrypts plain authenticating ad, it returns the random nonce followed by the ciphertext.
+func (ks *Keystore) seal(plain, ad []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(ks.key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, ad), nil
+}
+
+// open decrypts a box created by seal, it returns ErrWrongPassword if the key or ad are not the ones used to seal it.
+func (ks *Keystore) open(box, ad []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(ks.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(box) < aead.NonceSize() {
+		return nil, ErrWrongPassword
+	}
+	plain, err := aead.Open(nil, box[:aead.NonceSize()], box[aead.NonceSize():], ad)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	return plain, nil
+}
+
+// entryAD is the authenticated data of an entry: the label and the address.
+func entryAD(label, address string) []byte {
+	return []byte(label + "\x00" + address)
+}
+
+// save writes the keystore to a temporary file (readable only by the user) and renames it, so the file is never left half written.
+func (ks *Keystore) save() error {
+	data, err := json.MarshalIndent(ks.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := ks.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ks.path)
+}
+
+// find returns the index of the entry with the label or -1.
+func (ks *Keystore) find(label string) int {
+	for i, e := range ks.file.Entries {
+		if e.Label == label {
+			return i
+		}
+	}
+	return -1
+}
+
+// Add stores the keypair with the label, that must be a valid account name (see ValidateAccountName) not used by another entry.
+func (ks *Keystore) Add(label string, pair *keypair.Full) (err error) {
+	if err = ValidateAccountName(label); err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.find(label) >= 0 {
+		return errors.New("label " + label + " already exists in the keystore")
+	}
+	box, err := ks.seal([]byte(pair.Seed()), entryAD(label, pair.Address()))
+	if err != nil {
+		return err
+	}
+	ks.file.Entries = append(ks.file.Entries, keystoreEntry{Label: label, Address: pair.Address(), Box: box})
+	if err = ks.save(); err != nil {
+		ks.file.Entries = ks.file.Entries[:len(ks.file.Entries)-1]
+	}
+	return err
+}
+
+// List returns the labels and addresses of the entries sorted by label; it does not decrypt anything.
+func (ks *Keystore) List() (entries []KeystoreEntry) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for _, e := range ks.file.Entries {
+		entries = append(entries, KeystoreEntry{Label: e.Label, Address: e.Address})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Label < entries[j].Label })
+	return entries
+}
+
+// Export decrypts and returns the keypair stored with the label.
+func (ks *Keystore) Export(label string) (pair *keypair.Full, err error) {
+	ks.mu.Lock()
+	i := ks.find(label)
+	if i < 0 {
+		ks.mu.Unlock()
+		return nil, errors.New("label " + label + " not found in the keystore")
+	}
+	e := ks.file.Entries[i]
+	ks.mu.Unlock()
+
+	seed, err := ks.open(e.Box, entryAD(e.Label, e.Address))
+	if err != nil {
+		return nil, err
+	}
+	kp, err := keypair.Parse(string(seed))
+	if err != nil {
+		return nil, err
+	}
+	pair, ok := kp.(*keypair.Full)
+	if !ok || pair.Address() != e.Address {
+		return nil, errors.New("keystore entry " + label + " does not match its address")
+	}
+	return pair, nil
+}
+
+// Remove deletes the entry with the label.
+func (ks *Keystore) Remove(label string) (err error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	i := ks.find(label)
+	if i < 0 {
+		return errors.New("label " + label + " not found in the keystore")
+	}
+	entries := ks.file.Entries
+	ks.file.Entries = append(append([]keystoreEntry{}, entries[:i]...), entries[i+1:]...)
+	if err = ks.save(); err != nil {
+		ks.file.Entries = entries
+	}
+	return err
+}
+
+// defaultKeystore is the package keystore used by the signing helpers and defaultKeystoreMu protects it.
+var (
+	defaultKeystore   *Keystore
+	defaultKeystoreMu sync.RWMutex
+)
+
+// SetDefaultKeystore sets the package keystore used by MKey, MKeySigner, MSignLabels and MSignSubmitLabel (nil removes it).
+func SetDefaultKeystore(ks *Keystore) {
+	defaultKeystoreMu.Lock()
+	defaultKeystore = ks
+	defaultKeystoreMu.Unlock()
+}
+
+// DefaultKeystore returns the package keystore (nil if it is not set).
+func DefaultKeystore() *Keystore {
+	defaultKeystoreMu.RLock()
+	defer defaultKeystoreMu.RUnlock()
+	return defaultKeystore
+}
+
+// MKey returns the keypair stored with the label in the package keystore.
+func MKey(label string) (pair *keypair.Full, err error) {
+	ks := DefaultKeystore()
+	if ks == nil {
+		return nil, errors.New("no keystore set, see SetDefaultKeystore")
+	}
+	return ks.Export(label)
+}
+
+// MKeySigner returns a Signer with the key stored with the label in the package keystore (see Keystore.Signer).
+func MKeySigner(label string) (s Signer, err error) {
+	ks := DefaultKeystore()
+	if ks == nil {
+		return nil, errors.New("no keystore set, see SetDefaultKeystore")
+	}
+	return ks.Signer(label)
+}
+
+// MSignLabels signs the transaction with the keys stored with the labels in the package keystore.
+func MSignLabels(tx *build.TransactionBuilder, labels ...string) (txe build.TransactionEnvelopeBuilder, err error) {
+	signers := make([]Signer, len(labels))
+	for i, label := range labels {
+		if signers[i], err = MKeySigner(label); err != nil {
+			return txe, err
+		}
+	}
+	return MSign(tx, signers...)
+}
+
+// MSignSubmitLabel signs the transaction with the key stored with the label in the package keystore and sends it to Stellar.
+func MSignSubmitLabel(label string, tx *build.TransactionBuilder) (resp horizon.TransactionSuccess, err error) {
+	s, err := MKeySigner(label)
+	if err != nil {
+		return resp, err
+	}
+	return MSignSubmit(s, tx)
+}
@@ -0,0 +1,149 @@
+package colon
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+)
+
+//
+// LOGGING
+// The package does not write to stdout, all the messages go to the Logger set with SetLogger (by default nothing is logged).
+// The values that are seeds are always redacted before reaching the logger.
+//
+
+// Level is the severity of a log message.
+type Level int
+
+// Log levels.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the level name.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	}
+	return "ERROR"
+}
+
+// Logger receives the package messages; keyvals are key/value pairs (key1, value1, key2, value2...) with the message fields.
+type Logger interface {
+	Log(level Level, msg string, keyvals ...interface{})
+}
+
+// nopLogger discards all the messages.
+type nopLogger struct{}
+
+// Log does nothing.
+func (nopLogger) Log(level Level, msg string, keyvals ...interface{}) {}
+
+// logger is the package logger and loggerMu protects it.
+var (
+	logger   Logger = nopLogger{}
+	loggerMu sync.RWMutex
+)
+
+// SetLogger sets the package logger; nil sets the silent logger (the default).
+func SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	loggerMu.Lock()
+	logger = l
+	loggerMu.Unlock()
+}
+
+// logf sends a message to the package logger redacting the seeds of msg and keyvals.
+func logf(level Level, msg string, keyvals ...interface{}) {
+	loggerMu.RLock()
+	l := logger
+	loggerMu.RUnlock()
+	if _, ok := l.(nopLogger); ok {
+		return
+	}
+	kv := make([]interface{}, len(keyvals))
+	for i, v := range keyvals {
+		kv[i] = redact(v)
+	}
+	l.Log(level, redact(msg).(string), kv...)
+}
+
+// seedRe matches the strings that may be a seed.
+var seedRe = regexp.MustCompile(`S[A-Z2-7]{55}`)
+
+// redact replaces the seeds of a value by "<redacted>": the strings, errors and stringers are converted to string and the seeds inside them are replaced,
+// and a keypair is replaced by its address.
+func redact(v interface{}) interface{} {
+	var s string
+	switch x := v.(type) {
+	case *keypair.Full:
+		return x.Address()
+	case string:
+		s = x
+	case error:
+		s = x.Error()
+	case fmt.Stringer:
+		s = x.String()
+	default:
+		return v
+	}
+	r := seedRe.ReplaceAllStringFunc(s, func(m string) string {
+		if _, err := strkey.Decode(strkey.VersionByteSeed, m); err == nil {
+			return "<redacted>"
+		}
+		return m
+	})
+	if r == s {
+		return v
+	}
+	return r
+}
+
+// writerLogger writes the messages with level greater or equal than min as lines "LEVEL msg key=value...".
+type writerLogger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	min Level
+}
+
+// NewWriterLogger returns a Logger that writes to w the messages with level min or higher, one per line.
+func NewWriterLogger(w io.Writer, min Level) Logger {
+	return &writerLogger{w: w, min: min}
+}
+
+// Log writes the message line.
+func (wl *writerLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	if level < wl.min {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			fmt.Fprintf(&b, " %v=%v", keyvals[i], keyvals[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", keyvals[i])
+		}
+	}
+	b.WriteString("\n")
+	wl.mu.Lock()
+	io.WriteString(wl.w, b.String())
+	wl.mu.Unlock()
+}
@@ -3,7 +3,6 @@ package colon
 import (
 	"encoding/base32"
 	"encoding/base64"
-	"fmt"
 	"strings"
 
 	"github.com/go-errors/errors"
@@ -13,43 +12,25 @@ import (
 	"github.com/stellar/go/xdr"
 )
 
-// baseSeed is the base seed used to generate deterministic keypairs, the lenght is 24bytes
-var baseSeed string
-
-// Init sets the package base seed used to generate deterministic keypairs
+// Init sets the package base seed used to generate deterministic keypairs (legacy derivation, see NewKeyDeriverV1 for the KDF based one)
 func Init(seed string) (err error) {
-	l := len(seed)
-	if l < 8 {
-		return errors.New("Minimum seed length 8")
-	} else if l < 24 {
-		seed = seed + strings.Repeat(" ", 24-l)
-		//seed = seed + ("                ")[0:24-l]
-	} else if l > 24 {
-		return errors.New("Maximum seed length 24")
-	}
-	baseSeed = seed
+	kd, err := NewKeyDeriver(seed)
+	if err != nil {
+		return err
+	}
+	SetDefaultKeyDeriver(kd)
 	return nil
 }
 
-// DeterministicKeypair generates a keypair (seed+address) using baseSeed+accName as part of the seed; accName maximum length is 8.
+// DeterministicKeypair generates a keypair (seed+address) using baseSeed+accName as part of the seed; it returns nil if accName is not valid.
+// It uses the package KeyDeriver (see KeyDeriver.DeterministicKeypair).
 func DeterministicKeypair(accName string) (pair *keypair.Full) {
-	// if accName length is longer than 8 return nil because this is an error
-	if len(accName) > 8 || accName == "" {
-		return nil
-	}
-	// generate a [32]byte seed for the issuing account
-	bytes := []byte(baseSeed + accName)
-	byteSeed := [32]byte{}
-	bl := len(bytes)
-	for i := 0; i < bl; i++ {
-		byteSeed[i] = bytes[i]
-	}
-	// generate the keypair from the seed
-	var err error
-	if pair, err = keypair.FromRawSeed(byteSeed); err != nil {
-		return nil
-	}
-	return pair
+	return DefaultKeyDeriver().DeterministicKeypair(accName)
+}
+
+// Derive generates the keypair of the (optionally namespaced) account name accName with the package KeyDeriver, returning an error if the name is not valid.
+func Derive(accName string) (pair *keypair.Full, err error) {
+	return DefaultKeyDeriver().Derive(accName)
 }
 
 // MSeed2Bytes converts a seed into a [32]byte array
@@ -69,7 +50,7 @@ func MSeed2Bytes(seed string) (seedBytes [32]byte, err error) {
 
 // MLoadAccount gets the account data from the horizon server
 func MLoadAccount(addr string) (account horizon.Account, err error) {
-	if account, err = horizon.DefaultTestNetClient.LoadAccount(addr); err != nil {
+	if account, err = hClient().LoadAccount(addr); err != nil {
 		MHorizonProblemView(err)
 	}
 	return account, err
@@ -80,14 +61,14 @@ func MLoadAccount(addr string) (account horizon.Account, err error) {
 // This functions just embed some Stellar libraries functions to make easier to the beginner to do the drills without knowing Stellar libraries.
 //
 
-// MTrans builds a transaction with no operation, it only contains the source account and the autosequence for testnet.
+// MTrans builds a transaction with no operation, it only contains the source account and the autosequence for the package network (see SetNetwork).
 // The function automatically inserts the network, source account and autosequence mutators, in muts it can be added other mutators such as operations to add.
 func MTrans(addrOrSeed string, muts ...build.TransactionMutator) (tb *build.TransactionBuilder, err error) {
 	if muts == nil {
 		// It just calls the transaction function with the network, source account and autosequence
-		return build.Transaction(build.TestNetwork, build.SourceAccount{addrOrSeed}, build.AutoSequence{horizon.DefaultTestNetClient})
+		return build.Transaction(hNetwork(), build.SourceAccount{addrOrSeed}, build.AutoSequence{hClient()})
 	}
-	tm := []build.TransactionMutator{build.TestNetwork, build.SourceAccount{addrOrSeed}, build.AutoSequence{horizon.DefaultTestNetClient}}
+	tm := []build.TransactionMutator{hNetwork(), build.SourceAccount{addrOrSeed}, build.AutoSequence{hClient()}}
 	tm = append(tm, muts...)
 	return build.Transaction(tm...)
 }
@@ -102,16 +83,20 @@ func MOpsAdd(tb *build.TransactionBuilder, muts ...build.TransactionMutator) (er
 	return tb.Mutate(build.Defaults{})
 }
 
-// MSign signs the transaction with all the signers provided.
-func MSign(tx *build.TransactionBuilder, signers ...string) (txe build.TransactionEnvelopeBuilder, err error) {
-	// It just calls the transaction sign function with all the signers provided.
-	return tx.Sign(signers...)
+// MSign signs the transaction with all the signers provided (e.g. keypairs, keystore signers, see Signer).
+func MSign(tx *build.TransactionBuilder, signers ...Signer) (txe build.TransactionEnvelopeBuilder, err error) {
+	// It creates the envelope and adds the signature of each signer.
+	if err = txe.Mutate(tx); err != nil {
+		return txe, err
+	}
+	err = txe.Mutate(SignWith(tx.NetworkPassphrase, signers...))
+	return txe, err
 }
 
 // MSignAdd adds new signature to the transaction envelope; as is passed the envelope address it does not return the envelope to the caller.
-func MSignAdd(txe *build.TransactionEnvelopeBuilder, signer string) (err error) {
-	// It just calls the transaction envelope builder mutate with a sign object constructed with the signer string.
-	return txe.Mutate(build.Sign{signer})
+func MSignAdd(txe *build.TransactionEnvelopeBuilder, signer Signer) (err error) {
+	// It just calls the transaction envelope builder mutate with the signer for the package network.
+	return txe.Mutate(SignWith(CurrentNetwork().Passphrase, signer))
 }
 
 // MSubmit converts a transaction envelope builder to base64 and sends to Stellar through horizon server.
@@ -122,20 +107,24 @@ func MSubmit(txe build.TransactionEnvelopeBuilder) (resp horizon.TransactionSucc
 		return resp, err
 	}
 	// Send to Stellar
-	resp, err = horizon.DefaultTestNetClient.SubmitTransaction(txeB64)
+	resp, err = hClient().SubmitTransaction(txeB64)
 	if err != nil {
 		if strings.Contains(err.Error(), "error decoding horizon.Problem") {
 			// if there is a decoding problem usually is because of a horizon timeout, try submit a second time
-			resp, err = horizon.DefaultTestNetClient.SubmitTransaction(txeB64)
+			logf(LevelWarn, "horizon submit timeout, retrying", "err", err)
+			resp, err = hClient().SubmitTransaction(txeB64)
 		}
 	}
+	if err == nil {
+		logf(LevelInfo, "transaction successful", "ledger", resp.Ledger, "hash", resp.Hash)
+	}
 	return resp, err
 }
 
 // MSignSubmit signs the transaction, converts to base64 and sends to Stellar through horizon server.
-func MSignSubmit(seed string, tx *build.TransactionBuilder) (resp horizon.TransactionSuccess, err error) {
+func MSignSubmit(signer Signer, tx *build.TransactionBuilder) (resp horizon.TransactionSuccess, err error) {
 	// Sign the transaction to prove you are actually the person sending it.
-	txe, err := tx.Sign(seed)
+	txe, err := MSign(tx, signer)
 	if err != nil {
 		return resp, err
 	}
@@ -145,42 +134,38 @@ func MSignSubmit(seed string, tx *build.TransactionBuilder) (resp horizon.Transa
 		return resp, err
 	}
 	// Send to Stellar
-	resp, err = horizon.DefaultTestNetClient.SubmitTransaction(txeB64)
+	resp, err = hClient().SubmitTransaction(txeB64)
 	if err != nil {
 		MHorizonProblemView(err)
 		return resp, err
 	}
+	logf(LevelInfo, "transaction successful", "ledger", resp.Ledger, "hash", resp.Hash)
 	return resp, err
 }
 
-// MHorizonProblemView prints the details of an horizon error to be able to view what happens
+// MHorizonProblemView logs (level error) the details of an horizon error to be able to view what happens
 func MHorizonProblemView(err error) {
-	switch t := err.(type) {
-	case (*horizon.Error):
-	default:
-		fmt.Println("This is not a horizon.Error", t, err)
+	eo, ok := err.(*horizon.Error)
+	if !ok {
+		logf(LevelError, "this is not a horizon.Error", "err", err)
 		return
 	}
-	eo := err.(*horizon.Error)
 	eop := eo.Problem
-	fmt.Println("Horizon Problem", "status", eop.Status)
-	fmt.Println("- type:", eop.Type)
-	fmt.Println("- title:", eop.Title)
-	fmt.Println("- detail:", eop.Detail)
-	fmt.Println("- instance:", eop.Instance)
+	kv := []interface{}{"status", eop.Status, "type", eop.Type, "title", eop.Title, "detail", eop.Detail, "instance", eop.Instance}
 	for k, v := range eop.Extras {
-		fmt.Println("- map", k, ":", string(v))
+		kv = append(kv, k, string(v))
 	}
+	logf(LevelError, "horizon problem", kv...)
 }
 
 // MHorizonErrorResultCode extracts and returns from an error (that can be casted to horizon.Error) the transaction code and the operation codes.
+// If the error is not a horizon.Error it returns an error. It is MErrorCodes with the codes as strings.
 func MHorizonErrorResultCode(herr error) (txCode string, opCodes []string, err error) {
-	eo := herr.(*horizon.Error)
-	if rc, err := eo.ResultCodes(); err != nil {
-		return txCode, opCodes, err
-	} else {
-		return rc.TransactionCode, rc.OperationCodes, nil
+	tc, ocs, err := MErrorCodes(herr)
+	for _, c := range ocs {
+		opCodes = append(opCodes, string(c))
 	}
+	return string(tc), opCodes, err
 }
 
 // MXdrToTrans returns a transaction envelope from a base64 xdr string.
@@ -191,6 +176,32 @@ func MXdrToTrans(data string) (tx xdr.TransactionEnvelope, err error) {
 	return tx, err
 }
 
+// MSignXdr adds the signatures of the signers to a base64 transaction envelope xdr (e.g. built by another tool) and returns the signed envelope xdr.
+func MSignXdr(data string, signers ...Signer) (signed string, err error) {
+	tx, err := MXdrToTrans(data)
+	if err != nil {
+		return signed, err
+	}
+	txe := build.TransactionEnvelopeBuilder{E: &tx}
+	if err = txe.Mutate(SignWith(CurrentNetwork().Passphrase, signers...)); err != nil {
+		return signed, err
+	}
+	return txe.Base64()
+}
+
+// MSubmitXdr sends a signed base64 transaction envelope xdr to Stellar and returns its receipt.
+func MSubmitXdr(data string) (receipt Receipt, err error) {
+	tx, err := MXdrToTrans(data)
+	if err != nil {
+		return receipt, err
+	}
+	resp, err := MSubmit(build.TransactionEnvelopeBuilder{E: &tx})
+	if err != nil {
+		return receipt, err
+	}
+	return NewReceipt(resp), nil
+}
+
 //
 // BASIC TRANSACTION OPERATIONS
 // This functions just embed some Stellar libraries functions to make easier to the beginner to do the drills without knowing Stellar libraries.
@@ -201,7 +212,7 @@ func MXdrToTrans(data string) (tx xdr.TransactionEnvelope, err error) {
 //  - InflationDest: is a [32]byte with the address publickey
 //  - ClearFlags/SetFlags/MasterWeight/LowThreshold/MedThreshold/HighThreshold/HomeDOmain: is a uint32
 //  - Signer: is an interface array with [keyType int32, address/transaction/hash int32, weight uint32)
-func MSetOptions(pair *keypair.Full, opts map[string]interface{}) (err error) {
+func MSetOptions(pair Signer, opts map[string]interface{}) (receipt Receipt, err error) {
 	// create and fill the SetOptions with the opts map (other way is to create muts:=[]interface{}, compose options and then build.SetOptions(muts...) to create it)
 	so := build.SetOptions()
 	for k, v := range opts {
@@ -209,7 +220,7 @@ func MSetOptions(pair *keypair.Full, opts map[string]interface{}) (err error) {
 		case "InflationDest":
 			xpk, err := xdr.NewPublicKey(xdr.PublicKeyTypePublicKeyTypeEd25519, v.([32]byte))
 			if err != nil {
-				return err
+				return receipt, err
 			}
 			x := xdr.AccountId(xpk)
 			so.SO.InflationDest = &x
@@ -238,127 +249,133 @@ func MSetOptions(pair *keypair.Full, opts map[string]interface{}) (err error) {
 			xa := v.([]interface{})
 			xs, err := xdr.NewSignerKey(xdr.SignerKeyType(xa[0].(int32)), xdr.Uint256(xa[1].([32]byte)))
 			if err != nil {
-				return err
+				return receipt, err
 			}
 			x := xdr.Signer{Key: xs, Weight: xdr.Uint32(xa[2].(uint32))}
 			so.SO.Signer = &x
 		default:
-			return errors.New("wrong option")
+			return receipt, errors.New("wrong option")
 		}
 	}
 
 	// compose the setOptions trust transaction
-	seedDis := pair.Seed()
 	tx, err := build.Transaction(
-		build.TestNetwork,
+		hNetwork(),
 		build.SourceAccount{pair.Address()},
-		build.AutoSequence{horizon.DefaultTestNetClient},
+		build.AutoSequence{hClient()},
 		so,
 	)
 	if err != nil {
-		fmt.Println(err)
-		return err
+		logf(LevelError, "setOptions transaction build failed", "err", err)
+		return receipt, err
 	}
 	// Sign and submit the transaction
-	fmt.Println("SerOptions Transaction", "addr", pair.Address())
-	if resp, err := MSignSubmit(seedDis, tx); err == nil {
-		fmt.Println("..successful", "Ledger", resp.Ledger, "Hash", resp.Hash)
+	logf(LevelInfo, "setOptions transaction", "addr", pair.Address())
+	resp, err := MSignSubmit(pair, tx)
+	if err != nil {
+		return receipt, err
 	}
-	return err
+	return NewReceipt(resp), nil
 }
 
-// MTransPayment sends a payment transaction of amtStr from a pairSource address to a destination address.
-// Instead of using directly the source seed it is used the pairSource, in this way the seed is used for signing and the address for displaying.
-// If checkDest is set then the destination account is verified before sending (so no fee is paid if the address not exists).
-func MTransPayment(pairSource *keypair.Full, addrDest, asset, amtStr string, checkDest bool) (err error) {
+// MTransPayment sends a payment transaction of amt from a pairSource address to a destination address.
+// Instead of using directly the source seed it is used the pairSource (a keypair or any other Signer), it signs the transaction and its address is the source.
+// If checkDest is set then the destination account is verified before sending (so no fee is paid if the address not exists), if it does not exist returns an AccountNotFoundError.
+func MTransPayment(pairSource Signer, addrDest, asset string, amt Amount, checkDest bool) (receipt Receipt, err error) {
+	if amt <= 0 {
+		return receipt, errors.Errorf("invalid payment amount %s, it must be positive", amt)
+	}
 	// Make sure destination address exists, so no fees are paid if it does not exist
 	if checkDest {
-		if _, err := horizon.DefaultTestNetClient.LoadAccount(addrDest); err != nil {
-			panic(err)
+		if err = checkAccount(addrDest); err != nil {
+			return receipt, err
 		}
 	}
 
 	// Build the transaction
 	var pb build.PaymentBuilder
 	if asset == "" {
-		pb = build.Payment(build.Destination{addrDest}, build.NativeAmount{amtStr})
+		pb = build.Payment(build.Destination{addrDest}, build.NativeAmount{amt.String()})
 	} else {
-		pb = build.Payment(build.Destination{addrDest}, build.CreditAmount{asset, pairSource.Address(), amtStr})
+		pb = build.Payment(build.Destination{addrDest}, build.CreditAmount{asset, pairSource.Address(), amt.String()})
 	}
-	seedSource := pairSource.Seed()
 	tx, err := build.Transaction(
-		build.TestNetwork,
-		build.SourceAccount{seedSource},
-		build.AutoSequence{horizon.DefaultTestNetClient},
+		hNetwork(),
+		build.SourceAccount{pairSource.Address()},
+		build.AutoSequence{hClient()},
 		pb,
 	)
 	if err != nil {
-		return err
+		return receipt, err
 	}
 	// Sign and submit the transaction
-	fmt.Println("Payment Transaction", asset, amtStr, "from", pairSource.Address(), "to", addrDest)
-	if resp, err := MSignSubmit(seedSource, tx); err == nil {
-		fmt.Println("..successful", "Ledger", resp.Ledger, "Hash", resp.Hash)
+	logf(LevelInfo, "payment transaction", "asset", asset, "amount", amt, "from", pairSource.Address(), "to", addrDest)
+	resp, err := MSignSubmit(pairSource, tx)
+	if err != nil {
+		return receipt, err
 	}
-	return err
+	return NewReceipt(resp), nil
 }
 
 // MTransTrust generates a trust line from an address (obtained from pairDis) to an issuer address (addrIss).
-// The assCode and the limitStr indicate the asset name and the amount of the trustline; if checkIss is set checks that the address of pairDis exists (if not quits).
-func MTransTrust(pairDis *keypair.Full, assCode, addrIss, limitStr string, checkIss bool) (err error) {
+// The assCode and the limit indicate the asset name and the amount of the trustline; if checkIss is set checks that the issuer address exists (if not returns an AccountNotFoundError).
+func MTransTrust(pairDis Signer, assCode, addrIss string, limit Amount, checkIss bool) (receipt Receipt, err error) {
+	if limit < 0 {
+		return receipt, errors.Errorf("invalid trust limit %s, it can not be negative", limit)
+	}
 	// Make sure issuing address (addrIss) exists, so no fees are paid if it does not exist
 	if checkIss {
-		if _, err := horizon.DefaultTestNetClient.LoadAccount(addrIss); err != nil {
-			panic(err)
+		if err = checkAccount(addrIss); err != nil {
+			return receipt, err
 		}
 	}
 
 	// compose the trust transaction
-	seedDis := pairDis.Seed()
 	tx, err := build.Transaction(
-		build.TestNetwork,
-		build.SourceAccount{seedDis},
-		build.AutoSequence{horizon.DefaultTestNetClient},
-		build.Trust(assCode, addrIss, build.Limit(limitStr)),
+		hNetwork(),
+		build.SourceAccount{pairDis.Address()},
+		build.AutoSequence{hClient()},
+		build.Trust(assCode, addrIss, build.Limit(limit.String())),
 	)
 	if err != nil {
-		fmt.Println(err)
-		return err
+		logf(LevelError, "trust transaction build failed", "err", err)
+		return receipt, err
 	}
 	// Sign and submit the transaction
-	fmt.Println("Trust Transaction", assCode, limitStr, "from", pairDis.Address(), "to", addrIss)
-	if resp, err := MSignSubmit(seedDis, tx); err == nil {
-		fmt.Println("..successful", "Ledger", resp.Ledger, "Hash", resp.Hash)
+	logf(LevelInfo, "trust transaction", "asset", assCode, "limit", limit, "from", pairDis.Address(), "to", addrIss)
+	resp, err := MSignSubmit(pairDis, tx)
+	if err != nil {
+		return receipt, err
 	}
-	return err
+	return NewReceipt(resp), nil
 }
 
 // MAllowTrust makes the issuer (in keypair) allow trust to the address (addr) for the asset assCode.
-// If checkIss is set checks that the address of pairDis exists (if not quits).
-func MAllowTrust(pairIss *keypair.Full, assCode, addr string, authorize, checkAddr bool) (err error) {
+// If checkAddr is set checks that the address addr exists (if not returns an AccountNotFoundError).
+func MAllowTrust(pairIss Signer, assCode, addr string, authorize, checkAddr bool) (receipt Receipt, err error) {
 	// Make sure address exists, so no fees are paid if it does not exist
 	if checkAddr {
-		if _, err := horizon.DefaultTestNetClient.LoadAccount(addr); err != nil {
-			panic(err)
+		if err = checkAccount(addr); err != nil {
+			return receipt, err
 		}
 	}
 
 	// compose the allow trust transaction
-	seedDis := pairIss.Seed()
 	tx, err := build.Transaction(
-		build.TestNetwork,
+		hNetwork(),
 		build.SourceAccount{pairIss.Address()},
-		build.AutoSequence{horizon.DefaultTestNetClient},
+		build.AutoSequence{hClient()},
 		build.AllowTrust(build.Trustor{addr}, build.AllowTrustAsset{Code: assCode}, build.Authorize{Value: authorize}),
 	)
 	if err != nil {
-		fmt.Println(err)
-		return err
+		logf(LevelError, "allowTrust transaction build failed", "err", err)
+		return receipt, err
 	}
 	// Sign and submit the transaction
-	fmt.Println("AllowTrust Transaction", assCode, "from", pairIss.Address(), "to", addr, "baseFee", tx.BaseFee)
-	if resp, err := MSignSubmit(seedDis, tx); err == nil {
-		fmt.Println("..successful", "Ledger", resp.Ledger, "Hash", resp.Hash)
+	logf(LevelInfo, "allowTrust transaction", "asset", assCode, "from", pairIss.Address(), "to", addr, "authorize", authorize, "baseFee", tx.BaseFee)
+	resp, err := MSignSubmit(pairIss, tx)
+	if err != nil {
+		return receipt, err
 	}
-	return err
+	return NewReceipt(resp), nil
 }
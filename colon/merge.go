@@ -0,0 +1,86 @@
+package colon
+
+import (
+	"strconv"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/build"
+)
+
+// ErrMergeNotConfirmed is returned by MAccountMerge when the caller does not confirm the merge.
+var ErrMergeNotConfirmed = errors.New("account merge not confirmed")
+
+// SubentriesError is returned when the source account of a merge has subentries (trustlines, offers, signers or data entries).
+type SubentriesError struct {
+	Address    string
+	Subentries int32
+}
+
+// Error returns the error message.
+func (e *SubentriesError) Error() string {
+	return "account " + e.Address + " has " + strconv.Itoa(int(e.Subentries)) + " subentries, it cannot be merged"
+}
+
+// MergeOptions are the options of MAccountMerge.
+//   - Confirm is called with the source and destination addresses and the XLM balance of the source before sending anything, the merge is only done if it returns true
+//   - Cleanup removes the subentries of the source first (as MTeardown), otherwise a source with subentries returns a SubentriesError
+//   - Cosigners sign the transactions with the source signer (the merge needs the high threshold)
+type MergeOptions struct {
+	Confirm   func(source, dest string, balance Amount) bool
+	Cleanup   bool
+	Cosigners []Signer
+}
+
+// MAccountMerge merges the account of pair into the account addrDest and returns the XLM transferred (from the merge operation result).
+// It checks that the destination exists (AccountNotFoundError) and is not the source, that the source has no subentries (unless opts.Cleanup)
+// and that opts.Confirm confirms it (ErrMergeNotConfirmed, also if Confirm is nil).
+func MAccountMerge(pair Signer, addrDest string, opts MergeOptions) (merged Amount, receipt Receipt, err error) {
+	addr := pair.Address()
+	if addrDest == addr {
+		return 0, receipt, errors.New("account " + addr + " cannot be merged into itself")
+	}
+	if err = checkAccount(addrDest); err != nil {
+		return 0, receipt, err
+	}
+	acc, err := loadAccount(addr)
+	if err != nil {
+		return 0, receipt, err
+	}
+	if acc.SubentryCount > 0 && !opts.Cleanup {
+		return 0, receipt, &SubentriesError{Address: addr, Subentries: acc.SubentryCount}
+	}
+	balance, err := nativeBalance(acc)
+	if err != nil {
+		return 0, receipt, err
+	}
+	if opts.Confirm == nil || !opts.Confirm(addr, addrDest, balance) {
+		return 0, receipt, ErrMergeNotConfirmed
+	}
+
+	// remove the subentries and merge, the signer removals and the merge are in the last transaction
+	signers := append([]Signer{pair}, opts.Cosigners...)
+	ops, last := []build.TransactionMutator{}, []build.TransactionMutator{}
+	if acc.SubentryCount > 0 {
+		if ops, last, err = teardownOps(addr); err != nil {
+			return 0, receipt, err
+		}
+	}
+	logf(LevelInfo, "account merge", "addr", addr, "dest", addrDest, "balance", balance, "cleanup ops", len(ops)+len(last))
+	last = append(last, build.AccountMerge(build.Destination{addrDest}))
+	if _, receipt, err = sendOps(addr, ops, last, signers); err != nil {
+		return 0, receipt, err
+	}
+	merged = mergedAmount(receipt)
+	logf(LevelInfo, "account merged", "addr", addr, "dest", addrDest, "amount", merged)
+	return merged, receipt, nil
+}
+
+// mergedAmount returns the XLM transferred by the account merge operation of the receipt (the last one if there are several).
+func mergedAmount(receipt Receipt) (amt Amount) {
+	for _, or := range receipt.OpResults {
+		if or.Tr != nil && or.Tr.AccountMergeResult != nil && or.Tr.AccountMergeResult.SourceAccountBalance != nil {
+			amt = Amount(*or.Tr.AccountMergeResult.SourceAccountBalance)
+		}
+	}
+	return amt
+}
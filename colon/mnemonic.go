@@ -0,0 +1,84 @@
+package colon
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/keypair"
+	"github.com/tyler-smith/go-bip39"
+)
+
+//
+// MNEMONICS (SEP-0005)
+// A BIP39 mnemonic (12 or 24 words) is a backup phrase from which many accounts are derived at the path m/44'/148'/n' (SLIP-0010 ed25519),
+// the same accounts that the standard Stellar wallets restore from the phrase.
+//
+
+// sep5Purpose and sep5CoinType are the first levels of the SEP-0005 path m/44'/148'/n'.
+const (
+	sep5Purpose  = 44
+	sep5CoinType = 148
+)
+
+// hardened is the offset of the hardened indexes, ed25519 only has hardened derivation.
+const hardened uint32 = 0x80000000
+
+// NewMnemonic generates a random BIP39 mnemonic of words words (12, 15, 18, 21 or 24).
+func NewMnemonic(words int) (mnemonic string, err error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", errors.Errorf("invalid number of words %d, it must be 12, 15, 18, 21 or 24", words)
+	}
+	entropy, err := bip39.NewEntropy(words / 3 * 32)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// MnemonicDeriver derives the SEP-0005 accounts of a mnemonic; it is not modified after created so it is safe for concurrent use.
+type MnemonicDeriver struct {
+	// key and chain are the SLIP-0010 key and chain code of the path m/44'/148'
+	key   []byte
+	chain []byte
+}
+
+// NewMnemonicDeriver creates a MnemonicDeriver from a BIP39 mnemonic (imported from a wallet or generated with NewMnemonic) and an optional passphrase.
+func NewMnemonicDeriver(mnemonic, passphrase string) (md *MnemonicDeriver, err error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	// master key, then m/44'/148'
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chain := sum[:32], sum[32:]
+	for _, i := range []uint32{sep5Purpose, sep5CoinType} {
+		key, chain = slip10Child(key, chain, i)
+	}
+	return &MnemonicDeriver{key: key, chain: chain}, nil
+}
+
+// slip10Child derives the hardened child i of the SLIP-0010 ed25519 key and chain code.
+func slip10Child(key, chain []byte, i uint32) (childKey, childChain []byte) {
+	data := make([]byte, 1+32+4)
+	copy(data[1:], key)
+	binary.BigEndian.PutUint32(data[33:], i+hardened)
+	mac := hmac.New(sha512.New, chain)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+// Account derives the keypair of the account n (path m/44'/148'/n'); the first account of a wallet is n=0.
+func (md *MnemonicDeriver) Account(n uint32) (pair *keypair.Full, err error) {
+	if n >= hardened {
+		return nil, errors.Errorf("invalid account index %d", n)
+	}
+	key, _ := slip10Child(md.key, md.chain, n)
+	byteSeed := [32]byte{}
+	copy(byteSeed[:], key)
+	return keypair.FromRawSeed(byteSeed)
+}
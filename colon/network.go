@@ -0,0 +1,81 @@
+package colon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+)
+
+//
+// NETWORK
+// All the helpers use the package network (by default the test network): the horizon server where the accounts are loaded and the transactions sent,
+// and the passphrase that the transactions are signed for. Use SetNetwork to select the public network or another horizon server (e.g. a local one).
+//
+
+// Network is a Stellar network: its horizon client, passphrase and friendbot URL (empty if the network has no friendbot).
+type Network struct {
+	Name       string
+	Client     *horizon.Client
+	Passphrase string
+	Friendbot  string
+}
+
+// The Stellar test and public networks.
+var (
+	TestNet   = Network{Name: "test", Client: horizon.DefaultTestNetClient, Passphrase: build.TestNetwork.Passphrase, Friendbot: "https://friendbot.stellar.org"}
+	PublicNet = Network{Name: "public", Client: horizon.DefaultPublicNetClient, Passphrase: build.PublicNetwork.Passphrase}
+)
+
+// curNetwork is the package network and curNetworkMu protects it.
+var (
+	curNetwork   = TestNet
+	curNetworkMu sync.RWMutex
+)
+
+// SetNetwork sets the network used by the package.
+func SetNetwork(n Network) {
+	curNetworkMu.Lock()
+	curNetwork = n
+	curNetworkMu.Unlock()
+}
+
+// CurrentNetwork returns the network used by the package.
+func CurrentNetwork() Network {
+	curNetworkMu.RLock()
+	defer curNetworkMu.RUnlock()
+	return curNetwork
+}
+
+// hClient returns the horizon client of the package network.
+func hClient() *horizon.Client {
+	return CurrentNetwork().Client
+}
+
+// hNetwork returns the transaction mutator with the passphrase of the package network.
+func hNetwork() build.Network {
+	return build.Network{Passphrase: CurrentNetwork().Passphrase}
+}
+
+// MFund creates and funds the account addr with the friendbot of the package network (only the test network has one).
+func MFund(addr string) (err error) {
+	n := CurrentNetwork()
+	if n.Friendbot == "" {
+		return errors.New("network " + n.Name + " has no friendbot")
+	}
+	resp, err := http.Get(n.Friendbot + "/?addr=" + url.QueryEscape(addr))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("friendbot %s: %s", resp.Status, body)
+	}
+	logf(LevelInfo, "account funded by friendbot", "addr", addr)
+	return nil
+}
@@ -0,0 +1,256 @@
+package colon
+
+import (
+	"fmt"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+//
+// PRE-FLIGHT VALIDATION
+// Predicts the result codes of a transaction before sending it, so no fees are paid for a transaction that is going to fail.
+//
+
+// Finding is a failure predicted by MPreflight; Op is the operation index (-1 if it is a transaction failure).
+type Finding struct {
+	Op     int
+	Code   ResultCode
+	Detail string
+}
+
+// preflightAccount is the state of an account used while checking a transaction; account is nil if it does not exist.
+type preflightAccount struct {
+	account *horizon.Account
+	bals    map[Asset]Balance
+}
+
+// MPreflight loads the accounts involved in the transaction tb and returns the failures that the transaction would have if it were sent:
+// tx_no_source_account, op_no_destination, op_no_trust, op_not_authorized, op_src_no_trust, op_src_not_authorized, op_underfunded, op_line_full,
+// op_already_exists, op_invalid_limit, op_no_trustline and op_low_reserve (insufficient reserve after adding the transaction subentries).
+// The operations are checked in order updating the balances, so an operation can use the funds received in a previous one.
+// An empty findings list means that no failure is predicted; it does not check the signatures.
+func MPreflight(tb *build.TransactionBuilder) (findings []Finding, err error) {
+	accounts := map[string]*preflightAccount{}
+	load := func(addr string) (*preflightAccount, error) {
+		if pa, ok := accounts[addr]; ok {
+			return pa, nil
+		}
+		pa := &preflightAccount{}
+		if acc, err := loadAccount(addr); err == nil {
+			pa.account = &acc
+			if pa.bals, err = MLoadBalances(addr); err != nil {
+				return nil, err
+			}
+		} else if !IsAccountNotFound(err) {
+			return nil, err
+		}
+		accounts[addr] = pa
+		return pa, nil
+	}
+
+	txSource := tb.TX.SourceAccount.Address()
+	src, err := load(txSource)
+	if err != nil {
+		return nil, err
+	}
+	if src.account == nil {
+		return []Finding{{Op: -1, Code: TxNoAccount, Detail: "source account " + txSource + " does not exist"}}, nil
+	}
+
+	sources := map[string]bool{txSource: true}
+	for i, op := range tb.TX.Operations {
+		opSource := txSource
+		if op.SourceAccount != nil {
+			opSource = op.SourceAccount.Address()
+		}
+		sources[opSource] = true
+		src, err := load(opSource)
+		if err != nil {
+			return nil, err
+		}
+		if src.account == nil {
+			findings = append(findings, Finding{i, OpNoSourceAccount, "operation source account " + opSource + " does not exist"})
+			continue
+		}
+		var f *Finding
+		switch op.Body.Type {
+		case xdr.OperationTypeCreateAccount:
+			co := op.Body.CreateAccountOp
+			dst, err := load(co.Destination.Address())
+			if err != nil {
+				return nil, err
+			}
+			if dst.account != nil {
+				f = &Finding{i, OpAlreadyExists, "account " + co.Destination.Address() + " already exists"}
+			} else {
+				f = src.spend(XLM, Amount(co.StartingBalance))
+			}
+		case xdr.OperationTypePayment:
+			po := op.Body.PaymentOp
+			asset, err := xdrAsset(po.Asset)
+			if err != nil {
+				return nil, err
+			}
+			dst, err := load(po.Destination.Address())
+			if err != nil {
+				return nil, err
+			}
+			if dst.account == nil {
+				f = &Finding{i, OpNoDestination, "destination " + po.Destination.Address() + " does not exist"}
+			} else if f = src.checkSend(opSource, asset); f == nil {
+				if f = dst.checkReceive(po.Destination.Address(), asset, Amount(po.Amount)); f == nil {
+					f = src.spend(asset, Amount(po.Amount))
+					dst.receive(asset, Amount(po.Amount))
+				}
+			}
+		case xdr.OperationTypeChangeTrust:
+			ct := op.Body.ChangeTrustOp
+			asset, err := xdrAsset(ct.Line)
+			if err != nil {
+				return nil, err
+			}
+			iss, err := load(asset.Issuer)
+			if err != nil {
+				return nil, err
+			}
+			if iss.account == nil {
+				f = &Finding{i, OpNoIssuer, "issuer " + asset.Issuer + " does not exist"}
+			} else if b, ok := src.bals[asset]; ok && Amount(ct.Limit) < b.Balance+b.BuyingLiabilities {
+				f = &Finding{i, OpInvalidLimit, "limit " + Amount(ct.Limit).String() + " is lower than the balance " + b.Balance.String()}
+			} else if ct.Limit > 0 {
+				b.Asset, b.Limit, b.Authorized = asset, Amount(ct.Limit), ok && b.Authorized || !iss.account.Flags.AuthRequired
+				src.bals[asset] = b
+			} else {
+				delete(src.bals, asset)
+			}
+		case xdr.OperationTypeAllowTrust:
+			at := op.Body.AllowTrustOp
+			trustor, err := load(at.Trustor.Address())
+			if err != nil {
+				return nil, err
+			}
+			code := ""
+			if at.Asset.AssetCode4 != nil {
+				code = string(trimCode(at.Asset.AssetCode4[:]))
+			} else if at.Asset.AssetCode12 != nil {
+				code = string(trimCode(at.Asset.AssetCode12[:]))
+			}
+			asset := Asset{Code: code, Issuer: opSource}
+			if !src.account.Flags.AuthRequired {
+				f = &Finding{i, OpNotRequired, "issuer " + opSource + " has not the auth required flag"}
+			} else if b, ok := trustor.bals[asset]; trustor.account == nil || !ok {
+				f = &Finding{i, OpNoTrustline, "account " + at.Trustor.Address() + " has no trustline to " + asset.String()}
+			} else if !at.Authorize && !src.account.Flags.AuthRevocable {
+				f = &Finding{i, OpCantRevoke, "issuer " + opSource + " has not the auth revocable flag"}
+			} else {
+				b.Authorized = at.Authorize
+				trustor.bals[asset] = b
+			}
+		}
+		if f != nil {
+			f.Op = i
+			findings = append(findings, *f)
+		}
+	}
+
+	// reserves of the accounts that are source of the transaction or of an operation
+	baseReserve, err := MBaseReserve()
+	if err != nil {
+		return nil, err
+	}
+	for addr := range sources {
+		pa := accounts[addr]
+		if pa.account == nil {
+			continue
+		}
+		r, err := MReservePredict(*pa.account, baseReserve, tb)
+		if err != nil {
+			return nil, err
+		}
+		if r.Deficit > 0 {
+			findings = append(findings, Finding{-1, OpLowReserve, "account " + addr + " would be " + r.Deficit.String() + " XLM below its minimum balance " + r.MinBalance.String()})
+		}
+	}
+	return findings, nil
+}
+
+// checkSend checks that the account addr can send the asset: it has a trustline and it is authorized (if it is not the issuer); the balance is checked by spend.
+func (pa *preflightAccount) checkSend(addr string, asset Asset) *Finding {
+	if asset.IsNative() || asset.Issuer == addr {
+		return nil
+	}
+	b, ok := pa.bals[asset]
+	if !ok {
+		return &Finding{Code: OpSrcNoTrust, Detail: "source " + addr + " has no trustline to " + asset.String()}
+	}
+	if !b.Authorized {
+		return &Finding{Code: OpSrcNotAuthorized, Detail: "source " + addr + " is not authorized to hold " + asset.String()}
+	}
+	return nil
+}
+
+// checkReceive checks that the account addr can receive amt of the asset: trustline authorized (if it is not the issuer) and below the limit.
+func (pa *preflightAccount) checkReceive(addr string, asset Asset, amt Amount) *Finding {
+	if asset.IsNative() || asset.Issuer == addr {
+		return nil
+	}
+	b, ok := pa.bals[asset]
+	if !ok {
+		return &Finding{Code: OpNoTrust, Detail: "destination " + addr + " has no trustline to " + asset.String()}
+	}
+	if !b.Authorized {
+		return &Finding{Code: OpNotAuthorized, Detail: "destination " + addr + " is not authorized to hold " + asset.String()}
+	}
+	if nb, err := b.Balance.Add(amt); err != nil || nb > b.Limit {
+		return &Finding{Code: OpLineFull, Detail: "destination " + addr + " limit " + b.Limit.String() + " " + asset.String() + " would be exceeded"}
+	}
+	return nil
+}
+
+// spend subtracts amt of the asset from the account, if the available amount is not enough returns an op_underfunded finding.
+// The issuer of an asset has no balance of it and can always send it.
+func (pa *preflightAccount) spend(asset Asset, amt Amount) *Finding {
+	b, ok := pa.bals[asset]
+	if !ok {
+		return nil
+	}
+	if b.Available < amt {
+		return &Finding{Code: OpUnderfunded, Detail: "available " + b.Available.String() + " " + asset.String() + " is lower than " + amt.String()}
+	}
+	b.Balance -= amt
+	b.Available -= amt
+	pa.bals[asset] = b
+	return nil
+}
+
+// receive adds amt of the asset to the account (if it has a trustline, the issuer does not hold its own asset).
+func (pa *preflightAccount) receive(asset Asset, amt Amount) {
+	if b, ok := pa.bals[asset]; ok {
+		b.Balance += amt
+		b.Available += amt
+		pa.bals[asset] = b
+	}
+}
+
+// trimCode removes the trailing zeros of an xdr asset code.
+func trimCode(code []byte) []byte {
+	for len(code) > 0 && code[len(code)-1] == 0 {
+		code = code[:len(code)-1]
+	}
+	return code
+}
+
+// MPreflightError returns an error describing the findings (nil if there are no findings), to stop before sending a transaction.
+func MPreflightError(findings []Finding) (err error) {
+	if len(findings) == 0 {
+		return nil
+	}
+	msg := "preflight:"
+	for _, f := range findings {
+		msg += fmt.Sprintf(" [op %d] %s %s;", f.Op, f.Code, f.Detail)
+	}
+	return errors.New(msg)
+}
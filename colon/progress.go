@@ -0,0 +1,116 @@
+package colon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-errors/errors"
+)
+
+// Progress is the progress file of a scenario run: the scenario name, the addresses of its accounts, the number of steps completed (passed)
+// and the number of steps submitted. A step submitted but not completed did not pass, its result codes are kept so it is checked again without sending it again.
+type Progress struct {
+	Scenario     string            `json:"scenario"`
+	Accounts     map[string]string `json:"accounts"`
+	Completed    int               `json:"completed"`
+	Submitted    int               `json:"submitted"`
+	SubmittedTx  ResultCode        `json:"submitted_tx,omitempty"`
+	SubmittedOps []ResultCode      `json:"submitted_ops,omitempty"`
+}
+
+// LoadProgress reads a progress file, if it does not exist it returns an empty progress.
+func LoadProgress(path string) (p Progress, err error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	} else if err != nil {
+		return p, err
+	}
+	if err = json.Unmarshal(data, &p); err != nil {
+		return p, errors.New("progress " + path + ": " + err.Error())
+	}
+	return p, nil
+}
+
+// save writes the progress to a temporary file and renames it, so an interrupted run does not leave it half written.
+func (p Progress) save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// MRunScenarioResume executes the steps of the scenario after the ones completed in the progress file (created if it does not exist) and stops at the first step that fails;
+// the progress is written after each step is submitted and after it passes. A step submitted that did not pass (e.g. a payment with a wrong expected balance) is not
+// sent again: its expectations are checked again, to skip it set completed to the next step in the progress file.
+// The progress file must be of the same scenario and accounts, delete it to run the scenario again from the start.
+func MRunScenarioResume(sc *Scenario, signers map[string]Signer, progressPath string) (results []StepResult, err error) {
+	if err = sc.checkSigners(signers); err != nil {
+		return nil, err
+	}
+	p, err := LoadProgress(progressPath)
+	if err != nil {
+		return nil, err
+	}
+	if p.Accounts == nil {
+		p = Progress{Scenario: sc.Name, Accounts: map[string]string{}}
+		for _, name := range sc.Accounts {
+			p.Accounts[name] = signers[name].Address()
+		}
+	} else {
+		if p.Scenario != sc.Name {
+			return nil, errors.New("progress " + progressPath + " is of the scenario " + p.Scenario)
+		}
+		for _, name := range sc.Accounts {
+			if p.Accounts[name] != signers[name].Address() {
+				return nil, errors.New("progress " + progressPath + " has another address for the account " + name)
+			}
+		}
+		if p.Completed > len(sc.Steps) || p.Submitted > len(sc.Steps) {
+			return nil, errors.Errorf("progress %s: %d steps completed and %d submitted of %d", progressPath, p.Completed, p.Submitted, len(sc.Steps))
+		}
+		logf(LevelInfo, "scenario resumed", "scenario", sc.Name, "completed", p.Completed)
+	}
+	if err = p.save(progressPath); err != nil {
+		return nil, err
+	}
+
+	for i := p.Completed; i < len(sc.Steps); i++ {
+		s := sc.Steps[i]
+		var r StepResult
+		if i >= p.Submitted {
+			// the submission is recorded before checking the expectations, so a failed step is not sent again
+			if r = sc.execStep(signers, s); r.Err == nil {
+				p.Submitted, p.SubmittedTx, p.SubmittedOps = i+1, r.TxCode, r.OpCodes
+				if err = p.save(progressPath); err != nil {
+					return results, err
+				}
+			}
+		} else {
+			logf(LevelInfo, "scenario step already submitted, checked again", "scenario", sc.Name, "step", i, "name", s.Name)
+			r = StepResult{TxCode: p.SubmittedTx, OpCodes: p.SubmittedOps}
+		}
+		sc.checkStep(signers, s, &r)
+		r.Step, r.Name = i, s.Name
+		results = append(results, r)
+		if !r.Pass {
+			logf(LevelWarn, "scenario step failed", "scenario", sc.Name, "step", i, "name", s.Name, "problems", r.Problems, "err", r.Err)
+			if r.Err == nil {
+				return results, errors.Errorf("scenario %s: step %d failed, it was submitted and it is not sent again; fix it or set completed to %d in the progress to skip it", sc.Name, i, i+1)
+			}
+			return results, errors.Errorf("scenario %s: step %d failed, run it again to resume from it", sc.Name, i)
+		}
+		logf(LevelInfo, "scenario step passed", "scenario", sc.Name, "step", i, "name", s.Name)
+		p.Completed = i + 1
+		if err = p.save(progressPath); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
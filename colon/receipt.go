@@ -0,0 +1,82 @@
+package colon
+
+import (
+	"net/http"
+
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+//
+// RECEIPTS AND ERRORS
+// The transaction helpers return a Receipt of the transaction sent (to be used in audit logs or user interfaces), and the accounts checked before sending return an AccountNotFoundError if they do not exist.
+//
+
+// Receipt is the result of a successful transaction.
+//   - Hash and Ledger identify the transaction and the ledger where it was included
+//   - FeeCharged is the fee paid in XLM
+//   - EnvelopeXDR and ResultXDR are the base64 xdr of the transaction envelope and of the transaction result
+//   - TxCode, OpCodes and OpResults are the decoded transaction result (one code and one result per operation)
+//   - DecodeErr is the error decoding the result xdr; the transaction was applied anyway, so only TxCode (tx_success), Hash and Ledger are set
+type Receipt struct {
+	Hash        string
+	Ledger      int32
+	FeeCharged  Amount
+	EnvelopeXDR string
+	ResultXDR   string
+	TxCode      ResultCode
+	OpCodes     []ResultCode
+	OpResults   []xdr.OperationResult
+	DecodeErr   error
+}
+
+// NewReceipt builds the receipt of a transaction from the horizon response (e.g. of MSubmit) decoding the result xdr.
+// Horizon only returns a response for the transactions applied, so a result that can not be decoded is not an error (sending it again would repeat it),
+// it is reported in DecodeErr.
+func NewReceipt(resp horizon.TransactionSuccess) (r Receipt) {
+	r = Receipt{Hash: resp.Hash, Ledger: resp.Ledger, EnvelopeXDR: resp.Env, ResultXDR: resp.Result, TxCode: TxSuccess}
+	var tr xdr.TransactionResult
+	if err := xdr.SafeUnmarshalBase64(resp.Result, &tr); err != nil {
+		logf(LevelWarn, "transaction result not decoded", "hash", resp.Hash, "err", err)
+		r.DecodeErr = err
+		return r
+	}
+	r.FeeCharged = Amount(tr.FeeCharged)
+	r.TxCode = txResultCode(tr.Result.Code)
+	r.OpResults, _ = tr.Result.GetResults()
+	for _, or := range r.OpResults {
+		r.OpCodes = append(r.OpCodes, opResultCode(or))
+	}
+	return r
+}
+
+// AccountNotFoundError is returned when an account that must exist (e.g. the destination of a payment checked before sending) does not exist.
+type AccountNotFoundError struct {
+	Address string
+}
+
+// Error returns the error message.
+func (e *AccountNotFoundError) Error() string {
+	return "account " + e.Address + " not found"
+}
+
+// IsAccountNotFound returns true if err is an AccountNotFoundError.
+func IsAccountNotFound(err error) bool {
+	_, ok := err.(*AccountNotFoundError)
+	return ok
+}
+
+// loadAccount gets the account from the horizon server, if it does not exist it returns an AccountNotFoundError.
+func loadAccount(addr string) (account horizon.Account, err error) {
+	account, err = hClient().LoadAccount(addr)
+	if herr, ok := err.(*horizon.Error); ok && herr.Problem.Status == http.StatusNotFound {
+		return account, &AccountNotFoundError{Address: addr}
+	}
+	return account, err
+}
+
+// checkAccount returns an AccountNotFoundError if the account addr does not exist (or the error loading it).
+func checkAccount(addr string) (err error) {
+	_, err = loadAccount(addr)
+	return err
+}
@@ -0,0 +1,212 @@
+package colon
+
+import (
+	"fmt"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+//
+// RESERVES
+// An account must hold a minimum XLM balance of (2 + subentries) * base reserve, the subentries are the trustlines, offers, signers and data entries.
+// A transaction that adds a subentry fails if after it the balance is below the new minimum, these functions compute it before sending.
+//
+
+// Reserve is the reserve status of an account.
+//   - Subentries is the number of trustlines, offers, signers and data entries
+//   - MinBalance is (2 + Subentries) * BaseReserve
+//   - Spendable is the XLM that can be sent without going below MinBalance
+//   - Deficit is the XLM missing to reach MinBalance (zero if the balance is enough)
+type Reserve struct {
+	BaseReserve Amount
+	Subentries  int32
+	MinBalance  Amount
+	Balance     Amount
+	Spendable   Amount
+	Deficit     Amount
+}
+
+// DefaultBaseFee is the fee per operation (100 stroops) paid by the transactions built with build.Defaults.
+const DefaultBaseFee Amount = 100
+
+// MBaseReserve gets the base reserve of the last closed ledger from the horizon server.
+func MBaseReserve() (reserve Amount, err error) {
+	rec, err := latestLedger()
+	if err != nil {
+		return 0, err
+	}
+	// old horizon servers report the base reserve as an amount string
+	if rec.BaseReserveInStroops > 0 {
+		return Amount(rec.BaseReserveInStroops), nil
+	}
+	return ParseAmount(rec.BaseReserve)
+}
+
+// ledgerRecord is the part of a horizon ledger used by the package.
+type ledgerRecord struct {
+	Sequence             int32  `json:"sequence"`
+	BaseReserveInStroops int32  `json:"base_reserve_in_stroops"`
+	BaseReserve          string `json:"base_reserve"`
+}
+
+// latestLedger gets the last closed ledger from the horizon server.
+func latestLedger() (rec ledgerRecord, err error) {
+	var page struct {
+		Embedded struct {
+			Records []ledgerRecord `json:"records"`
+		} `json:"_embedded"`
+	}
+	if err = hGet("/ledgers?order=desc&limit=1", &page); err != nil {
+		return rec, err
+	}
+	if len(page.Embedded.Records) == 0 {
+		return rec, errors.New("no ledgers")
+	}
+	return page.Embedded.Records[0], nil
+}
+
+// MReserve computes the reserve status of an account loaded with MLoadAccount, baseReserve is usually obtained with MBaseReserve.
+func MReserve(account horizon.Account, baseReserve Amount) (r Reserve, err error) {
+	bal, err := nativeBalance(account)
+	if err != nil {
+		return r, err
+	}
+	return newReserve(baseReserve, account.SubentryCount, bal), nil
+}
+
+// nativeBalance returns the XLM balance of the account.
+func nativeBalance(account horizon.Account) (Amount, error) {
+	bal, err := account.GetNativeBalance()
+	if err != nil {
+		return 0, err
+	}
+	return ParseAmount(bal)
+}
+
+// newReserve fills a Reserve from the base reserve, the number of subentries and the XLM balance.
+func newReserve(baseReserve Amount, subentries int32, bal Amount) (r Reserve) {
+	r = Reserve{BaseReserve: baseReserve, Subentries: subentries, Balance: bal}
+	r.MinBalance = Amount(2+subentries) * baseReserve
+	if bal >= r.MinBalance {
+		r.Spendable = bal - r.MinBalance
+	} else {
+		r.Deficit = r.MinBalance - bal
+	}
+	return r
+}
+
+// MReservePredict computes the reserve status that the account would have after applying the transaction tb (without sending it).
+// It counts the subentries added or removed by the operations whose source is the account (new trustline, signer, offer or data entry)
+// and subtracts the XLM it sends (native payments, created accounts starting balance and the fee, base fee * operations, if it is the transaction source).
+func MReservePredict(account horizon.Account, baseReserve Amount, tb *build.TransactionBuilder) (r Reserve, err error) {
+	bal, err := nativeBalance(account)
+	if err != nil {
+		return r, err
+	}
+	subentries := account.SubentryCount
+
+	// current subentries of the account, to know if an operation adds a new one or changes an existing one
+	trustlines := map[Asset]bool{}
+	for _, b := range account.Balances {
+		if b.Type != "native" {
+			trustlines[Asset{Code: b.Code, Issuer: b.Issuer}] = true
+		}
+	}
+	signers := map[string]bool{}
+	for _, s := range account.Signers {
+		signers[s.PublicKey] = true
+	}
+	data := map[string]bool{}
+	for k := range account.Data {
+		data[k] = true
+	}
+
+	// the fee is paid by the transaction source, it is computed (base fee * operations) as tb.TX.Fee is only set by build.Defaults
+	txSource := tb.TX.SourceAccount.Address()
+	if txSource == account.AccountID {
+		baseFee := DefaultBaseFee
+		if tb.BaseFee > 0 {
+			baseFee = Amount(tb.BaseFee)
+		}
+		bal -= baseFee * Amount(len(tb.TX.Operations))
+	}
+	for _, op := range tb.TX.Operations {
+		source := txSource
+		if op.SourceAccount != nil {
+			source = op.SourceAccount.Address()
+		}
+		if source != account.AccountID {
+			continue
+		}
+		switch op.Body.Type {
+		case xdr.OperationTypeCreateAccount:
+			bal -= Amount(op.Body.CreateAccountOp.StartingBalance)
+		case xdr.OperationTypePayment:
+			if op.Body.PaymentOp.Asset.Type == xdr.AssetTypeAssetTypeNative {
+				bal -= Amount(op.Body.PaymentOp.Amount)
+			}
+		case xdr.OperationTypeChangeTrust:
+			asset, err := xdrAsset(op.Body.ChangeTrustOp.Line)
+			if err != nil {
+				return r, err
+			}
+			if op.Body.ChangeTrustOp.Limit == 0 && trustlines[asset] {
+				subentries--
+				trustlines[asset] = false
+			} else if op.Body.ChangeTrustOp.Limit > 0 && !trustlines[asset] {
+				subentries++
+				trustlines[asset] = true
+			}
+		case xdr.OperationTypeSetOptions:
+			if s := op.Body.SetOptionsOp.Signer; s != nil {
+				addr := s.Key.Address()
+				if s.Weight == 0 && signers[addr] {
+					subentries--
+					signers[addr] = false
+				} else if s.Weight > 0 && !signers[addr] {
+					subentries++
+					signers[addr] = true
+				}
+			}
+		case xdr.OperationTypeManageOffer:
+			mo := op.Body.ManageOfferOp
+			if mo.OfferId == 0 && mo.Amount > 0 {
+				subentries++
+			} else if mo.OfferId != 0 && mo.Amount == 0 {
+				subentries--
+			}
+		case xdr.OperationTypeCreatePassiveOffer:
+			subentries++
+		case xdr.OperationTypeManageData:
+			name := string(op.Body.ManageDataOp.DataName)
+			if op.Body.ManageDataOp.DataValue == nil && data[name] {
+				subentries--
+				data[name] = false
+			} else if op.Body.ManageDataOp.DataValue != nil && !data[name] {
+				subentries++
+				data[name] = true
+			}
+		}
+	}
+	return newReserve(baseReserve, subentries, bal), nil
+}
+
+// xdrAsset converts an xdr asset into an Asset.
+func xdrAsset(xa xdr.Asset) (asset Asset, err error) {
+	var typ, code, issuer string
+	if err = xa.Extract(&typ, &code, &issuer); err != nil {
+		return asset, err
+	}
+	if typ == "native" {
+		return XLM, nil
+	}
+	return Asset{Code: code, Issuer: issuer}, nil
+}
+
+// String returns a one line description of the reserve status.
+func (r Reserve) String() string {
+	return fmt.Sprint("subentries ", r.Subentries, " minBalance ", r.MinBalance, " balance ", r.Balance, " spendable ", r.Spendable, " deficit ", r.Deficit)
+}
@@ -0,0 +1,498 @@
+package colon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+)
+
+//
+// SCENARIOS
+// A scenario is a JSON file that describes a drill: the named accounts, the assets and the steps with their expected result codes and balances.
+// MRunScenario executes the steps against the package network (testnet by default, or a mock horizon set with SetNetwork) and reports pass/fail per step.
+//
+//	{
+//	  "name": "drill0",
+//	  "accounts": ["A", "B"],
+//	  "assets": {"VEF": {"code": "VEF", "issuer": "A"}},
+//	  "steps": [
+//	    {"action": "fund", "account": "A"},
+//	    {"action": "fund", "account": "B"},
+//	    {"name": "no trust", "action": "pay", "account": "A", "to": "B", "asset": "VEF", "amount": "100", "expect": {"tx": "tx_failed", "ops": ["op_no_trust"]}},
+//	    {"action": "trust", "account": "B", "asset": "VEF", "amount": "500"},
+//	    {"action": "pay", "account": "A", "to": "B", "asset": "VEF", "amount": "100", "expect": {"balances": {"B": {"VEF": "100"}}}}
+//	  ]
+//	}
+//
+// The actions are:
+//   - fund: creates the account with the friendbot (if it does not exist)
+//   - create: the account creates the account to with a starting balance of amount XLM
+//   - pay: the account pays amount of the asset (XLM if empty) to the account to
+//   - trust: the account creates or changes its trustline to the asset with limit amount ("0" removes it, empty is the maximum)
+//   - allow: the account (issuer) authorizes, or revokes with "authorize": false, the trustline of the trustor to the asset
+//   - set-options: the account sets setFlags/clearFlags (auth_required, auth_revocable), masterWeight, low/med/high thresholds, homeDomain and a signer {account, weight}
+//   - multi: one transaction of the account with the operations of ops (each op with its own account), to practice multisignature
+//
+// The transactions are signed by signers (account names), by default the step account (for multi also the accounts of the ops).
+// The expected tx code is tx_success if it is not set; the op codes and balances (account -> asset -> amount) are only checked if set.
+// MRunScenarioResume stops at the first step that fails and records the completed steps in a progress file, so running it again continues from that step.
+//
+
+// Scenario actions.
+const (
+	ActionFund       = "fund"
+	ActionCreate     = "create"
+	ActionPay        = "pay"
+	ActionTrust      = "trust"
+	ActionAllow      = "allow"
+	ActionSetOptions = "set-options"
+	ActionMulti      = "multi"
+)
+
+// Scenario is a drill described as named accounts, assets and steps.
+type Scenario struct {
+	Name     string                   `json:"name"`
+	Accounts []string                 `json:"accounts"`
+	Assets   map[string]ScenarioAsset `json:"assets"`
+	Steps    []Step                   `json:"steps"`
+}
+
+// ScenarioAsset is an asset of a scenario, Issuer is the name of the issuing account.
+type ScenarioAsset struct {
+	Code   string `json:"code"`
+	Issuer string `json:"issuer"`
+}
+
+// Step is a scenario step, the fields used depend on the action (see the SCENARIOS description).
+type Step struct {
+	Name         string      `json:"name"`
+	Action       string      `json:"action"`
+	Account      string      `json:"account"`
+	To           string      `json:"to"`
+	Trustor      string      `json:"trustor"`
+	Asset        string      `json:"asset"`
+	Amount       string      `json:"amount"`
+	Authorize    *bool       `json:"authorize"`
+	SetFlags     []string    `json:"setFlags"`
+	ClearFlags   []string    `json:"clearFlags"`
+	MasterWeight *uint32     `json:"masterWeight"`
+	Low          *uint32     `json:"low"`
+	Med          *uint32     `json:"med"`
+	High         *uint32     `json:"high"`
+	HomeDomain   string      `json:"homeDomain"`
+	Signer       *StepSigner `json:"signer"`
+	Ops          []Step      `json:"ops"`
+	Signers      []string    `json:"signers"`
+	Expect       Expect      `json:"expect"`
+}
+
+// StepSigner is a signer added (or removed with weight 0) by a set-options step.
+type StepSigner struct {
+	Account string `json:"account"`
+	Weight  uint32 `json:"weight"`
+}
+
+// Expect is the expected outcome of a step: transaction code (tx_success if empty), operation codes and balances by account and asset.
+type Expect struct {
+	Tx       ResultCode                   `json:"tx"`
+	Ops      []ResultCode                 `json:"ops"`
+	Balances map[string]map[string]string `json:"balances"`
+}
+
+// StepResult is the result of a step: Pass is false if the codes or balances are not the expected ones (Problems describes why) or if it could not be executed (Err).
+// Skipped is true if the step was already done (e.g. the account funded) and nothing was sent.
+type StepResult struct {
+	Step     int
+	Name     string
+	Pass     bool
+	Skipped  bool
+	TxCode   ResultCode
+	OpCodes  []ResultCode
+	Hash     string
+	Problems []string
+	Err      error
+}
+
+// LoadScenario reads and validates a scenario file.
+func LoadScenario(path string) (sc *Scenario, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc = &Scenario{}
+	if err = json.Unmarshal(data, sc); err != nil {
+		return nil, errors.New("scenario " + path + ": " + err.Error())
+	}
+	return sc, sc.Validate()
+}
+
+// Validate checks that the accounts, assets, actions and amounts of the scenario are right, so it does not fail in the middle of a run.
+func (sc *Scenario) Validate() (err error) {
+	accounts := map[string]bool{}
+	for _, name := range sc.Accounts {
+		if err = ValidateAccountName(name); err != nil {
+			return err
+		}
+		if accounts[name] {
+			return errors.New("scenario account " + name + " repeated")
+		}
+		accounts[name] = true
+	}
+	for key, a := range sc.Assets {
+		if a.Code == "" || len(a.Code) > 12 || !accounts[a.Issuer] {
+			return errors.New("scenario asset " + key + ": wrong code or unknown issuer")
+		}
+	}
+	// fail returns the error of the step i
+	fail := func(i int, msg string) error {
+		return errors.Errorf("scenario step %d (%s): %s", i, sc.Steps[i].Name, msg)
+	}
+	check := func(i int, s Step, inMulti bool) error {
+		for _, name := range append([]string{s.Account}, s.Signers...) {
+			if !accounts[name] {
+				return fail(i, "unknown account \""+name+"\"")
+			}
+		}
+		if s.Asset != "" && s.Asset != "XLM" {
+			if _, ok := sc.Assets[s.Asset]; !ok {
+				return fail(i, "unknown asset \""+s.Asset+"\"")
+			}
+		}
+		if s.Amount != "" {
+			if _, err := ParseAmount(s.Amount); err != nil {
+				return fail(i, err.Error())
+			}
+		}
+		switch s.Action {
+		case ActionFund:
+			if inMulti {
+				return fail(i, "fund is not an operation")
+			}
+		case ActionCreate, ActionPay:
+			if !accounts[s.To] || s.Amount == "" {
+				return fail(i, "unknown account \""+s.To+"\" or no amount")
+			}
+		case ActionTrust:
+			if s.Asset == "" || s.Asset == "XLM" {
+				return fail(i, "trust needs a credit asset")
+			}
+		case ActionAllow:
+			if !accounts[s.Trustor] || s.Asset == "" || s.Asset == "XLM" || sc.Assets[s.Asset].Issuer != s.Account {
+				return fail(i, "allow needs a trustor and an asset issued by the account")
+			}
+		case ActionSetOptions:
+			for _, f := range append(append([]string{}, s.SetFlags...), s.ClearFlags...) {
+				if f != "auth_required" && f != "auth_revocable" {
+					return fail(i, "unknown flag \""+f+"\"")
+				}
+			}
+			if s.Signer != nil && !accounts[s.Signer.Account] {
+				return fail(i, "unknown signer account \""+s.Signer.Account+"\"")
+			}
+		case ActionMulti:
+			if inMulti || len(s.Ops) == 0 {
+				return fail(i, "multi needs ops (and cannot be nested)")
+			}
+		default:
+			return fail(i, "unknown action \""+s.Action+"\"")
+		}
+		return nil
+	}
+	for i, s := range sc.Steps {
+		if err = check(i, s, false); err != nil {
+			return err
+		}
+		for _, op := range s.Ops {
+			if err = check(i, op, true); err != nil {
+				return err
+			}
+		}
+		for name, bals := range s.Expect.Balances {
+			if !accounts[name] {
+				return fail(i, "unknown account \""+name+"\" in the expected balances")
+			}
+			for key, amt := range bals {
+				if _, ok := sc.Assets[key]; !ok && key != "XLM" {
+					return fail(i, "unknown asset \""+key+"\" in the expected balances")
+				}
+				if _, err := ParseAmount(amt); err != nil {
+					return fail(i, err.Error())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// MScenarioSigners derives the keypairs of the scenario accounts with kd (the package KeyDeriver if nil); if namespace is not empty
+// the accounts are derived as namespace/name, so each run can use new accounts.
+func MScenarioSigners(sc *Scenario, kd *KeyDeriver, namespace string) (signers map[string]Signer, err error) {
+	if kd == nil {
+		kd = DefaultKeyDeriver()
+	}
+	signers = map[string]Signer{}
+	for _, name := range sc.Accounts {
+		accName := name
+		if namespace != "" {
+			accName = namespace + "/" + name
+		}
+		pair, err := kd.Derive(accName)
+		if err != nil {
+			return nil, err
+		}
+		signers[name] = pair
+	}
+	return signers, nil
+}
+
+// MRunScenario executes the steps of the scenario with the signers of its accounts (e.g. from MScenarioSigners) and returns the result of each step.
+// The steps are all executed even if some fail, if any step does not pass it also returns an error.
+func MRunScenario(sc *Scenario, signers map[string]Signer) (results []StepResult, err error) {
+	if err = sc.checkSigners(signers); err != nil {
+		return nil, err
+	}
+	failed := 0
+	for i, s := range sc.Steps {
+		r := sc.runStep(signers, s)
+		r.Step, r.Name = i, s.Name
+		if r.Pass {
+			logf(LevelInfo, "scenario step passed", "scenario", sc.Name, "step", i, "name", s.Name)
+		} else {
+			failed++
+			logf(LevelWarn, "scenario step failed", "scenario", sc.Name, "step", i, "name", s.Name, "problems", r.Problems, "err", r.Err)
+		}
+		results = append(results, r)
+	}
+	if failed > 0 {
+		return results, errors.Errorf("scenario %s: %d of %d steps failed", sc.Name, failed, len(sc.Steps))
+	}
+	return results, nil
+}
+
+// checkSigners validates the scenario and checks that there is a signer for each account.
+func (sc *Scenario) checkSigners(signers map[string]Signer) (err error) {
+	if err = sc.Validate(); err != nil {
+		return err
+	}
+	for _, name := range sc.Accounts {
+		if signers[name] == nil {
+			return errors.New("no signer for the scenario account " + name)
+		}
+	}
+	return nil
+}
+
+// runStep executes a step and checks the expected codes and balances.
+func (sc *Scenario) runStep(signers map[string]Signer, s Step) (r StepResult) {
+	r = sc.execStep(signers, s)
+	sc.checkStep(signers, s, &r)
+	return r
+}
+
+// execStep executes a step and returns its result codes. The fund steps, and the trust and allow steps expected to succeed,
+// are skipped if they are already done (see MEnsureFunded, MEnsureTrust and MEnsureAllowTrust), so a scenario can be run again.
+func (sc *Scenario) execStep(signers map[string]Signer, s Step) (r StepResult) {
+	switch {
+	case s.Action == ActionFund:
+		var funded bool
+		funded, r.Err = MEnsureFunded(signers[s.Account].Address())
+		r.Skipped = !funded
+	case sc.ensured(s):
+		var receipt Receipt
+		receipt, r.Skipped, r.Err = sc.ensureStep(signers, s)
+		if _, ok := r.Err.(*horizon.Error); ok {
+			r.TxCode, r.OpCodes, r.Err = MErrorCodes(r.Err)
+		} else if r.Skipped {
+			r.TxCode = TxSuccess
+		} else {
+			r.TxCode, r.OpCodes, r.Hash = receipt.TxCode, receipt.OpCodes, receipt.Hash
+		}
+	default:
+		r.TxCode, r.OpCodes, r.Hash, r.Err = sc.sendStep(signers, s)
+	}
+	return r
+}
+
+// checkStep checks the expected codes and balances of a step executed with the result r, it sets its Problems and Pass.
+func (sc *Scenario) checkStep(signers map[string]Signer, s Step, r *StepResult) {
+	if r.Err != nil {
+		return
+	}
+	if s.Action != ActionFund {
+		expTx := s.Expect.Tx
+		if expTx == "" {
+			expTx = TxSuccess
+		}
+		if r.TxCode != expTx {
+			r.Problems = append(r.Problems, "tx code "+string(r.TxCode)+", expected "+string(expTx))
+		}
+		if s.Expect.Ops != nil && !MSameCodes(r.OpCodes, s.Expect.Ops) {
+			r.Problems = append(r.Problems, "op codes "+codesString(r.OpCodes)+", expected "+codesString(s.Expect.Ops))
+		}
+	}
+
+	// expected balances
+	for name, bals := range s.Expect.Balances {
+		for key, amt := range bals {
+			want, _ := ParseAmount(amt)
+			asset := XLM
+			if key != "XLM" {
+				asset = Asset{Code: sc.Assets[key].Code, Issuer: signers[sc.Assets[key].Issuer].Address()}
+			}
+			bal, err := MBalanceOf(signers[name].Address(), asset)
+			if err != nil {
+				r.Problems = append(r.Problems, "balance of "+name+" "+key+": "+err.Error())
+			} else if bal.Balance != want {
+				r.Problems = append(r.Problems, "balance of "+name+" "+key+" "+bal.Balance.String()+", expected "+want.String())
+			}
+		}
+	}
+	r.Pass = len(r.Problems) == 0
+}
+
+// ensured returns true if the step is a trust or allow step expected to succeed and signed only by its account, so it can be skipped if it is already done.
+func (sc *Scenario) ensured(s Step) bool {
+	if s.Action != ActionTrust && s.Action != ActionAllow || s.Expect.Tx != "" && s.Expect.Tx != TxSuccess || s.Expect.Ops != nil {
+		return false
+	}
+	return len(s.Signers) == 0 || len(s.Signers) == 1 && s.Signers[0] == s.Account
+}
+
+// ensureStep executes a trust step with MEnsureTrust (without limit it is MaxAmount) or an allow step with MEnsureAllowTrust.
+func (sc *Scenario) ensureStep(signers map[string]Signer, s Step) (receipt Receipt, skipped bool, err error) {
+	code, issuer := sc.Assets[s.Asset].Code, signers[sc.Assets[s.Asset].Issuer].Address()
+	if s.Action == ActionAllow {
+		return MEnsureAllowTrust(signers[s.Account], code, signers[s.Trustor].Address(), s.Authorize == nil || *s.Authorize, false)
+	}
+	limit := MaxAmount
+	if s.Amount != "" {
+		if limit, err = ParseAmount(s.Amount); err != nil {
+			return receipt, false, err
+		}
+	}
+	return MEnsureTrust(signers[s.Account], code, issuer, limit, false)
+}
+
+// sendStep builds, signs and sends the transaction of a step, it returns its result codes (the failures reported by horizon are not errors).
+func (sc *Scenario) sendStep(signers map[string]Signer, s Step) (txCode ResultCode, opCodes []ResultCode, hash string, err error) {
+	ops, names := []Step{s}, []string{s.Account}
+	if s.Action == ActionMulti {
+		ops = s.Ops
+		for _, op := range ops {
+			names = append(names, op.Account)
+		}
+	}
+	if len(s.Signers) > 0 {
+		names = s.Signers
+	}
+	muts := []build.TransactionMutator{}
+	for _, op := range ops {
+		mut, err := sc.operation(signers, op)
+		if err != nil {
+			return txCode, opCodes, hash, err
+		}
+		muts = append(muts, mut)
+	}
+	// each signer signs once even if it is the account of several operations
+	txSigners, seen := []Signer{}, map[string]bool{}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			txSigners = append(txSigners, signers[name])
+		}
+	}
+
+	tb, err := MTrans(signers[s.Account].Address(), muts...)
+	if err != nil {
+		return txCode, opCodes, hash, err
+	}
+	if err = MOpsAdd(tb); err != nil {
+		return txCode, opCodes, hash, err
+	}
+	txe, err := MSign(tb, txSigners...)
+	if err != nil {
+		return txCode, opCodes, hash, err
+	}
+	resp, err := MSubmit(txe)
+	if err != nil {
+		if _, ok := err.(*horizon.Error); !ok {
+			return txCode, opCodes, hash, err
+		}
+		txCode, opCodes, err = MErrorCodes(err)
+		return txCode, opCodes, hash, err
+	}
+	receipt := NewReceipt(resp)
+	return receipt.TxCode, receipt.OpCodes, receipt.Hash, nil
+}
+
+// operation returns the operation of a step with the step account as operation source account.
+func (sc *Scenario) operation(signers map[string]Signer, s Step) (build.TransactionMutator, error) {
+	source := build.SourceAccount{signers[s.Account].Address()}
+	asset, code, issuer := s.Asset, "", ""
+	if asset != "" && asset != "XLM" {
+		code, issuer = sc.Assets[asset].Code, signers[sc.Assets[asset].Issuer].Address()
+	}
+	switch s.Action {
+	case ActionCreate:
+		return build.CreateAccount(source, build.Destination{signers[s.To].Address()}, build.NativeAmount{s.Amount}), nil
+	case ActionPay:
+		if code == "" {
+			return build.Payment(source, build.Destination{signers[s.To].Address()}, build.NativeAmount{s.Amount}), nil
+		}
+		return build.Payment(source, build.Destination{signers[s.To].Address()}, build.CreditAmount{code, issuer, s.Amount}), nil
+	case ActionTrust:
+		if s.Amount == "" {
+			return build.Trust(code, issuer, source), nil
+		}
+		return build.Trust(code, issuer, source, build.Limit(s.Amount)), nil
+	case ActionAllow:
+		authorize := s.Authorize == nil || *s.Authorize
+		return build.AllowTrust(source, build.Trustor{signers[s.Trustor].Address()}, build.AllowTrustAsset{Code: code}, build.Authorize{Value: authorize}), nil
+	case ActionSetOptions:
+		muts := []interface{}{source}
+		for _, f := range s.SetFlags {
+			if f == "auth_required" {
+				muts = append(muts, build.SetAuthRequired())
+			} else {
+				muts = append(muts, build.SetAuthRevocable())
+			}
+		}
+		for _, f := range s.ClearFlags {
+			if f == "auth_required" {
+				muts = append(muts, build.ClearAuthRequired())
+			} else {
+				muts = append(muts, build.ClearAuthRevocable())
+			}
+		}
+		if s.MasterWeight != nil {
+			muts = append(muts, build.MasterWeight(*s.MasterWeight))
+		}
+		if s.Low != nil || s.Med != nil || s.High != nil {
+			muts = append(muts, build.Thresholds{Low: s.Low, Medium: s.Med, High: s.High})
+		}
+		if s.HomeDomain != "" {
+			muts = append(muts, build.HomeDomain(s.HomeDomain))
+		}
+		if s.Signer != nil {
+			muts = append(muts, build.AddSigner(signers[s.Signer.Account].Address(), s.Signer.Weight))
+		}
+		return build.SetOptions(muts...), nil
+	}
+	return nil, errors.New("action " + s.Action + " is not an operation")
+}
+
+// codesString returns the codes as [code1 code2...].
+func codesString(codes []ResultCode) string {
+	s := "["
+	for i, c := range codes {
+		if i > 0 {
+			s += " "
+		}
+		s += strconv.Quote(string(c))
+	}
+	return s + "]"
+}
@@ -0,0 +1,240 @@
+package colon
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+//
+// SIGNERS
+// A Signer signs the transaction hashes of an account without exposing its seed, all the signing helpers accept Signers:
+//   - a *keypair.Full is a Signer (the seed in memory)
+//   - Keystore.Signer returns a Signer that decrypts the seed from the keystore only while signing
+//   - SocketSigner asks another process to sign through a local socket (see ServeSigner), so the seeds are never in the drills process;
+//     the requests carry a shared token (see NewSignerToken) and the unix socket created by ListenSigner (in a private directory) is only accessible by the user
+//
+
+// Signer is the public key (address) of an account and a function that signs a 32 bytes transaction hash with its private key (ed25519).
+type Signer interface {
+	Address() string
+	Sign(hash []byte) ([]byte, error)
+}
+
+// signWith is the transaction envelope mutator that adds the signatures of signers for the network passphrase.
+type signWith struct {
+	passphrase string
+	signers    []Signer
+}
+
+// SignWith returns a transaction envelope mutator that signs the envelope with the signers, to use with TransactionEnvelopeBuilder.Mutate.
+func SignWith(passphrase string, signers ...Signer) build.TransactionEnvelopeMutator {
+	return signWith{passphrase: passphrase, signers: signers}
+}
+
+// MutateTransactionEnvelope adds the signatures to the envelope.
+func (sw signWith) MutateTransactionEnvelope(txe *build.TransactionEnvelopeBuilder) error {
+	hash, err := network.HashTransaction(&txe.E.Tx, sw.passphrase)
+	if err != nil {
+		return err
+	}
+	for _, s := range sw.signers {
+		ds, err := signDecorated(s, hash)
+		if err != nil {
+			return err
+		}
+		txe.E.Signatures = append(txe.E.Signatures, ds)
+	}
+	return nil
+}
+
+// signDecorated signs the hash with the signer, the signature hint is the last 4 bytes of its public key.
+func signDecorated(s Signer, hash [32]byte) (ds xdr.DecoratedSignature, err error) {
+	pub, err := strkey.Decode(strkey.VersionByteAccountID, s.Address())
+	if err != nil {
+		return ds, err
+	}
+	sig, err := s.Sign(hash[:])
+	if err != nil {
+		return ds, errors.New("signer " + s.Address() + ": " + err.Error())
+	}
+	copy(ds.Hint[:], pub[len(pub)-4:])
+	ds.Signature = xdr.Signature(sig)
+	return ds, nil
+}
+
+// keystoreSigner signs with the key of a keystore entry.
+type keystoreSigner struct {
+	ks      *Keystore
+	label   string
+	address string
+}
+
+// Signer returns a Signer with the key stored with the label; the seed is decrypted each time it signs and is not kept.
+func (ks *Keystore) Signer(label string) (s Signer, err error) {
+	for _, e := range ks.List() {
+		if e.Label == label {
+			return keystoreSigner{ks: ks, label: label, address: e.Address}, nil
+		}
+	}
+	return nil, errors.New("label " + label + " not found in the keystore")
+}
+
+// Address returns the address of the entry.
+func (s keystoreSigner) Address() string {
+	return s.address
+}
+
+// Sign decrypts the entry key and signs the hash.
+func (s keystoreSigner) Sign(hash []byte) ([]byte, error) {
+	pair, err := s.ks.Export(s.label)
+	if err != nil {
+		return nil, err
+	}
+	return pair.Sign(hash)
+}
+
+// SocketSignerTimeout is the maximum time a SocketSigner waits for a signature.
+var SocketSignerTimeout = 30 * time.Second
+
+// signRequest and signResponse are the messages (one JSON per line) between a SocketSigner and ServeSigner.
+type signRequest struct {
+	Token   string `json:"token"`
+	Address string `json:"address"`
+	Hash    []byte `json:"hash"`
+}
+
+type signResponse struct {
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SocketSigner is a Signer of the account Addr that asks the signer process listening at Network/Socket (e.g. "unix", "/tmp/colon.sock") to sign,
+// Token is the token of the signer process (see ServeSigner).
+type SocketSigner struct {
+	Network string
+	Socket  string
+	Addr    string
+	Token   string
+}
+
+// Address returns the account address.
+func (s SocketSigner) Address() string {
+	return s.Addr
+}
+
+// Sign sends the hash to the signer process and returns its signature.
+func (s SocketSigner) Sign(hash []byte) ([]byte, error) {
+	conn, err := net.DialTimeout(s.Network, s.Socket, SocketSignerTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(SocketSignerTimeout))
+	if err = json.NewEncoder(conn).Encode(signRequest{Token: s.Token, Address: s.Addr, Hash: hash}); err != nil {
+		return nil, err
+	}
+	var resp signResponse
+	if err = json.NewDecoder(bufio.NewReader(conn)).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Signature, nil
+}
+
+// NewSignerToken returns a random token for ServeSigner (64 hex characters), to be shared with the SocketSigners (e.g. in an environment variable).
+func NewSignerToken() (token string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ListenSigner listens in the unix socket path for ServeSigner, the socket file is only readable and writable by the user (0600).
+// The directory of the socket is created (0700) if it does not exist and it must not be accessible by other users,
+// otherwise they could connect between the socket creation and the change of its permissions.
+func ListenSigner(path string) (l net.Listener, err error) {
+	dir := filepath.Dir(path)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	st, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if st.Mode().Perm()&0077 != 0 {
+		return nil, errors.Errorf("signer socket directory %s is accessible by other users (%v), it must be 0700", dir, st.Mode().Perm())
+	}
+	if l, err = net.Listen("unix", path); err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// ServeSigner answers the SocketSigner requests received by l with the signers (one per address) until l is closed.
+// Only the requests with the token are signed (it can not be empty), and every request is logged with the address and the hash.
+// The socket should also be reachable only by the user (see ListenSigner).
+func ServeSigner(l net.Listener, token string, signers ...Signer) (err error) {
+	if token == "" {
+		return errors.New("empty signer token")
+	}
+	byAddr := map[string]Signer{}
+	for _, s := range signers {
+		byAddr[s.Address()] = s
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serveSignRequest(conn, token, byAddr)
+	}
+}
+
+// serveSignRequest answers one request; only 32 bytes hashes are signed.
+func serveSignRequest(conn net.Conn, token string, byAddr map[string]Signer) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(SocketSignerTimeout))
+	var req signRequest
+	var resp signResponse
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		resp.Error = "bad request: " + err.Error()
+	} else if subtle.ConstantTimeCompare([]byte(req.Token), []byte(token)) != 1 {
+		resp.Error = "wrong signer token"
+	} else if s, ok := byAddr[req.Address]; !ok {
+		resp.Error = "no signer for " + req.Address
+	} else if len(req.Hash) != 32 {
+		resp.Error = "the hash must have 32 bytes"
+	} else if resp.Signature, err = s.Sign(req.Hash); err != nil {
+		resp.Error = err.Error()
+	}
+	if resp.Error == "" {
+		logf(LevelInfo, "sign request signed", "addr", req.Address, "hash", hex.EncodeToString(req.Hash))
+	} else {
+		logf(LevelWarn, "sign request rejected", "addr", req.Address, "hash", hex.EncodeToString(req.Hash), "error", resp.Error)
+	}
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// the keypairs are signers
+var _ Signer = (*keypair.Full)(nil)
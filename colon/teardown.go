@@ -0,0 +1,174 @@
+package colon
+
+import (
+	"strconv"
+
+	"github.com/go-errors/errors"
+	"github.com/stellar/go/build"
+)
+
+//
+// TEARDOWN
+// An account can only be merged when it has no subentries, MTeardown removes them and merges the account into a collector account, so the XLM
+// of the test accounts is not left on the network. The operations are, in this order:
+//   - cancel the open offers
+//   - pay the credit asset balances back to their issuers (the issuer must exist and, with auth required, the trustline must be authorized)
+//   - remove the trustlines (limit 0)
+//   - remove the data entries
+//   - remove the signers other than the master key (ed25519, pre-authorized transaction and hash-x)
+//   - merge the account into the collector
+// They are sent in transactions of up to MaxOpsPerTrans operations, the signer removals and the merge are always in the last one, so the cosigners
+// can sign every transaction. The merge needs the high threshold, so the account must be signed by enough signers (the account signer and the cosigners).
+// MAccountMerge merges a single account with safety checks (the destination exists, no subentries unless cleanup, the caller confirms).
+//
+
+// TeardownResult is the result of tearing down an account: the hashes of the transactions sent, if it was merged (and the XLM transferred to the collector)
+// and the error if it failed.
+type TeardownResult struct {
+	Address string
+	Hashes  []string
+	Merged  bool
+	Amount  Amount
+	Err     error
+}
+
+// MTeardown removes the offers, trustlines (paying the balances to the issuers), data entries and signers of the account of pair and merges it into
+// the collector account; the transactions are signed by pair and the cosigners. If it fails the result has the hashes of the transactions already sent.
+func MTeardown(pair Signer, collector string, cosigners ...Signer) (r TeardownResult, err error) {
+	addr := pair.Address()
+	r.Address = addr
+	if collector == addr {
+		return r, errors.New("teardown " + addr + ": the collector is the account")
+	}
+	// the collector must exist, otherwise the merge fails with op_no_destination after the rest has been removed
+	if err = checkAccount(collector); err != nil {
+		return r, err
+	}
+	ops, last, err := teardownOps(addr)
+	if err != nil {
+		return r, err
+	}
+	last = append(last, build.AccountMerge(build.Destination{collector}))
+	hashes, receipt, err := sendOps(addr, ops, last, append([]Signer{pair}, cosigners...))
+	r.Hashes = hashes
+	if err != nil {
+		return r, err
+	}
+	r.Merged, r.Amount = true, mergedAmount(receipt)
+	logf(LevelInfo, "account merged", "addr", addr, "collector", collector, "amount", r.Amount, "transactions", len(r.Hashes))
+	return r, nil
+}
+
+// teardownOps returns the operations that remove the subentries of the account addr (without the merge): ops removes the offers, trustlines
+// and data entries, signerOps the signers (they must be in the transaction of the merge).
+func teardownOps(addr string) (ops, signerOps []build.TransactionMutator, err error) {
+	acc, err := loadAccount(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	offers, err := loadOffers(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, o := range offers {
+		rate := build.Rate{Selling: offerAsset(o.Selling), Buying: offerAsset(o.Buying), Price: build.Price(o.Price)}
+		ops = append(ops, build.DeleteOffer(rate, build.OfferID(o.ID)))
+	}
+	issuers := map[string]bool{}
+	for _, b := range acc.Balances {
+		if b.Type == "native" {
+			continue
+		}
+		bal, err := ParseAmount(b.Balance)
+		if err != nil {
+			return nil, nil, err
+		}
+		if bal > 0 {
+			if _, ok := issuers[b.Issuer]; !ok {
+				issuers[b.Issuer] = checkAccount(b.Issuer) == nil
+			}
+			if !issuers[b.Issuer] {
+				return nil, nil, errors.New("teardown " + addr + ": the issuer " + b.Issuer + " of " + b.Code + " does not exist, the balance cannot be returned")
+			}
+			ops = append(ops, build.Payment(build.Destination{b.Issuer}, build.CreditAmount{b.Code, b.Issuer, bal.String()}))
+		}
+		ops = append(ops, build.RemoveTrust(b.Code, b.Issuer))
+	}
+	for name := range acc.Data {
+		ops = append(ops, build.ClearData(name))
+	}
+	// the key of every signer type is its strkey (G..., T... for the pre-authorized transactions and X... for the hash-x)
+	for _, s := range acc.Signers {
+		key := signerKey(s)
+		if key != addr {
+			signerOps = append(signerOps, build.SetOptions(build.RemoveSigner(key)))
+		}
+	}
+	return ops, signerOps, nil
+}
+
+// sendOps sends the operations of the account addr in transactions of up to MaxOpsPerTrans operations signed by the signers, the operations last
+// are all sent in the last transaction. It returns the hashes of the transactions sent and the receipt of the last one.
+func sendOps(addr string, ops, last []build.TransactionMutator, signers []Signer) (hashes []string, receipt Receipt, err error) {
+	if len(last) > MaxOpsPerTrans {
+		return nil, receipt, errors.New("account " + addr + ": " + strconv.Itoa(len(last)) + " operations do not fit in the last transaction")
+	}
+	for len(ops)+len(last) > 0 {
+		chunk := ops
+		if len(ops)+len(last) <= MaxOpsPerTrans {
+			chunk, ops, last = append(ops[:len(ops):len(ops)], last...), nil, nil
+		} else {
+			if len(chunk) > MaxOpsPerTrans {
+				chunk = chunk[:MaxOpsPerTrans]
+			}
+			ops = ops[len(chunk):]
+		}
+		tb, err := MTrans(addr, chunk...)
+		if err != nil {
+			return hashes, receipt, err
+		}
+		txe, err := MSign(tb, signers...)
+		if err != nil {
+			return hashes, receipt, err
+		}
+		logf(LevelInfo, "account operations transaction", "addr", addr, "ops", len(chunk))
+		resp, err := MSubmit(txe)
+		if err != nil {
+			return hashes, receipt, err
+		}
+		hashes = append(hashes, resp.Hash)
+		receipt = NewReceipt(resp)
+	}
+	return hashes, receipt, nil
+}
+
+// MTeardownAll tears down each account (see MTeardown) into the collector signing with its signer and the cosigners, the accounts that do not exist are skipped.
+// It continues after a failure and returns the result of each account and an error if any failed.
+func MTeardownAll(pairs []Signer, collector string, cosigners ...Signer) (results []TeardownResult, err error) {
+	failed := 0
+	for _, pair := range pairs {
+		r, err := MTeardown(pair, collector, cosigners...)
+		if nf, ok := err.(*AccountNotFoundError); ok && nf.Address == pair.Address() {
+			logf(LevelInfo, "teardown account not found, skipped", "addr", pair.Address())
+			err = nil
+		}
+		if err != nil {
+			failed++
+			r.Err = err
+			logf(LevelWarn, "teardown failed", "addr", pair.Address(), "err", err)
+		}
+		results = append(results, r)
+	}
+	if failed > 0 {
+		return results, errors.New("teardown: " + strconv.Itoa(failed) + " of " + strconv.Itoa(len(pairs)) + " accounts failed")
+	}
+	return results, nil
+}
+
+// offerAsset returns the build asset of an offer asset.
+func offerAsset(a hOfferAsset) build.Asset {
+	if a.AssetType == "native" {
+		return build.NativeAsset()
+	}
+	return build.CreditAsset(a.AssetCode, a.AssetIssuer)
+}
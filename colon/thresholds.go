@@ -0,0 +1,147 @@
+package colon
+
+import (
+	"bytes"
+
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+//
+// THRESHOLD ANALYSIS
+// Each source account of a transaction (the transaction source and the operation sources) must sign with a weight of at least the threshold
+// of the highest level used by its operations: low for allow_trust, inflation and bump_sequence (and the transaction source itself), high for account_merge and
+// set_options changing the master weight, the thresholds or the signers, medium for the others.
+// MSignatureCheck shows, before submitting, which accounts have enough signatures; only the ed25519 signers are checked (not the hash or preauth ones).
+//
+
+// ThresholdLevel is the threshold level needed by an operation.
+type ThresholdLevel int
+
+// Threshold levels.
+const (
+	ThresholdLow ThresholdLevel = iota
+	ThresholdMed
+	ThresholdHigh
+)
+
+// String returns low, med or high.
+func (l ThresholdLevel) String() string {
+	return [...]string{"low", "med", "high"}[l]
+}
+
+// SignatureCheck is the threshold analysis of a source account: the level needed and its threshold, the weight of the signers that signed
+// and the signers that did not sign. OK is true if Weight reaches the threshold (at least 1); Exists is false if the account was not found.
+type SignatureCheck struct {
+	Account   string
+	Exists    bool
+	Level     ThresholdLevel
+	Threshold uint32
+	Weight    uint32
+	Signed    []string
+	Unsigned  []string
+	OK        bool
+}
+
+// opLevel returns the threshold level needed by an operation.
+func opLevel(op xdr.Operation) ThresholdLevel {
+	switch op.Body.Type {
+	case xdr.OperationTypeAllowTrust, xdr.OperationTypeInflation, xdr.OperationTypeBumpSequence:
+		return ThresholdLow
+	case xdr.OperationTypeAccountMerge:
+		return ThresholdHigh
+	case xdr.OperationTypeSetOptions:
+		so := op.Body.SetOptionsOp
+		if so != nil && (so.MasterWeight != nil || so.LowThreshold != nil || so.MedThreshold != nil || so.HighThreshold != nil || so.Signer != nil) {
+			return ThresholdHigh
+		}
+	}
+	return ThresholdMed
+}
+
+// MSignatureCheck loads the signers and thresholds of the source accounts of the envelope and checks its signatures, it returns one check per account
+// (the transaction source first).
+func MSignatureCheck(txe xdr.TransactionEnvelope) (checks []SignatureCheck, err error) {
+	hash, err := network.HashTransaction(&txe.Tx, CurrentNetwork().Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	// the highest level needed by each source account
+	txSource := txe.Tx.SourceAccount.Address()
+	order, levels := []string{txSource}, map[string]ThresholdLevel{txSource: ThresholdLow}
+	for _, op := range txe.Tx.Operations {
+		source := txSource
+		if op.SourceAccount != nil {
+			source = op.SourceAccount.Address()
+		}
+		level, ok := levels[source]
+		if !ok {
+			order = append(order, source)
+		}
+		if l := opLevel(op); !ok || l > level {
+			levels[source] = l
+		}
+	}
+
+	for _, addr := range order {
+		c := SignatureCheck{Account: addr, Level: levels[addr]}
+		acc, err := loadAccount(addr)
+		if IsAccountNotFound(err) {
+			checks = append(checks, c)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		c.Exists = true
+		c.Threshold = uint32([...]byte{acc.Thresholds.LowThreshold, acc.Thresholds.MedThreshold, acc.Thresholds.HighThreshold}[c.Level])
+		for _, s := range acc.Signers {
+			key := signerKey(s)
+			if s.Weight == 0 || s.Type != "" && s.Type != "ed25519_public_key" {
+				continue
+			}
+			if signedBy(txe.Signatures, key, hash) {
+				c.Weight += uint32(s.Weight)
+				c.Signed = append(c.Signed, key)
+			} else {
+				c.Unsigned = append(c.Unsigned, key)
+			}
+		}
+		needed := c.Threshold
+		if needed == 0 {
+			needed = 1
+		}
+		c.OK = c.Weight >= needed
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+// signedBy returns true if one of the signatures is a valid signature of the hash by the address.
+func signedBy(sigs []xdr.DecoratedSignature, addr string, hash [32]byte) bool {
+	pub, err := strkey.Decode(strkey.VersionByteAccountID, addr)
+	if err != nil {
+		return false
+	}
+	kp, err := keypair.Parse(addr)
+	if err != nil {
+		return false
+	}
+	for _, ds := range sigs {
+		if bytes.Equal(ds.Hint[:], pub[len(pub)-4:]) && kp.Verify(hash[:], ds.Signature) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// signerKey returns the key of the signer as a strkey (G..., T... or X...), old horizon servers only report the public key.
+func signerKey(s horizon.Signer) string {
+	if s.Key == "" {
+		return s.PublicKey
+	}
+	return s.Key
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"testing"
 
 	"github.com/8manuel/colongo/colon"
@@ -63,6 +64,137 @@ func TestAddrGenDet0(t *testing.T) {
 	log.Printf("Extracted from seed %s, this \"%s\"\n", seed, btyeSeed)
 }
 
+func TestAddrGenDetDeriver(t *testing.T) {
+	// two classrooms with different base seeds generate different keypairs for the same account name
+	kd1, err := colon.NewKeyDeriver("ClassroomOne")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	kd2, err := colon.NewKeyDeriver("ClassroomTwo")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	addr1, addr2 := kd1.DeterministicKeypair("A").Address(), kd2.DeterministicKeypair("A").Address()
+	log.Printf("Account A classroom one %s, classroom two %s\n", addr1, addr2)
+	if addr1 == addr2 {
+		t.Error("same address for different base seeds")
+	}
+
+	// the derivers can be used concurrently and always generate the same keypair
+	done := make(chan string)
+	for i := 0; i < 4; i++ {
+		go func() { done <- kd1.DeterministicKeypair("A").Address() }()
+	}
+	for i := 0; i < 4; i++ {
+		if addr := <-done; addr != addr1 {
+			t.Error("different address", addr, addr1)
+		}
+	}
+}
+
+func TestAddrGenDetV1(t *testing.T) {
+	// the KDF based derivation generates the same keypair for the same base seed, salt and name
+	kd, err := colon.NewKeyDeriverV1("BaseDrillSeedStr20180522", "colongo-drills")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	pair := kd.DeterministicKeypair("A")
+	if pair == nil {
+		t.Error(errors.New("failed DeterministicKeypair"))
+		return
+	}
+	if again := kd.DeterministicKeypair("A"); again.Address() != pair.Address() {
+		t.Error("different address for the same name", again.Address(), pair.Address())
+	}
+	// with another salt the keypair is different
+	kdSalt, err := colon.NewKeyDeriverV1("BaseDrillSeedStr20180522", "another-course")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if other := kdSalt.DeterministicKeypair("A"); other.Address() == pair.Address() {
+		t.Error("same address for different salts")
+	}
+	log.Printf("Keypair v1 Address %s\n", pair.Address())
+}
+
+func TestAddrGenDetLongName(t *testing.T) {
+	// names longer than 8 characters and namespaced names are derived with a hash
+	for _, name := range []string{"issuer-vef", "student042-distributor", "course/student042/distributor"} {
+		pair, err := colon.Derive(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		log.Printf("Name %s, Address %s\n", name, pair.Address())
+	}
+	// invalid names return an error describing the problem
+	for _, name := range []string{"", "course//A", "with space", "/course/A"} {
+		if _, err := colon.Derive(name); err == nil {
+			t.Error("no error for name", name)
+		} else {
+			log.Println(err)
+		}
+	}
+}
+
+func TestAddrGenDetLegacyBytes(t *testing.T) {
+	// the legacy names of up to 8 bytes are not validated, they keep the raw seed baseSeed+name
+	kd, err := colon.NewKeyDeriver("ClassroomOne")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"A", "a b", "x/y", "/A", "acc-ñ"} {
+		pair, err := kd.Derive(name)
+		if err != nil {
+			t.Error(name, err)
+			continue
+		}
+		byteSeed := [32]byte{}
+		copy(byteSeed[:], "ClassroomOne"+strings.Repeat(" ", 12)+name)
+		want, err := keypair.FromRawSeed(byteSeed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pair.Address() != want.Address() {
+			t.Error(name, "address", pair.Address(), "want", want.Address())
+		}
+	}
+}
+
+func TestAddrGenMnemonic(t *testing.T) {
+	// SEP-0005 test vector 1, the accounts must be the ones restored by the wallets
+	md, err := colon.NewMnemonicDeriver("illness spike retreat truth genius clock brain pass fit cave bargain toe", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, want := range []string{"GDRXE2BQUC3AZNPVFSCEZ76NJ3WWL25FYFK6RGZGIEKWE4SOOHSUJUJ6", "GBAW5XGWORWVFE2XTJYDTLDHXTY2Q2MO73HYCGB3XMFMQ562Q2W2GJQX"} {
+		pair, err := md.Account(uint32(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pair.Address() != want {
+			t.Errorf("account %d: address %s, expected %s", n, pair.Address(), want)
+		}
+	}
+
+	// a new mnemonic is valid and a wrong one is rejected
+	mnemonic, err := colon.NewMnemonic(24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Println("Mnemonic", mnemonic)
+	if _, err = colon.NewMnemonicDeriver(mnemonic, ""); err != nil {
+		t.Error(err)
+	}
+	if _, err = colon.NewMnemonicDeriver("illness spike retreat truth genius clock brain pass fit cave bargain bargain", ""); err == nil {
+		t.Error("no error for an invalid mnemonic")
+	}
+}
+
 func TestAddrGenDet1(t *testing.T) {
 	// generate a [32]byte seed
 	bytes := []byte("no se como hacer esto asi que me lo invento")
@@ -2,18 +2,28 @@ package test
 
 import (
 	"fmt"
-	"io/ioutil"
 	"log"
-	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/8manuel/colongo/colon"
+	"github.com/8manuel/colongo/colon/colontest"
 	"github.com/stellar/go/build"
 	"github.com/stellar/go/keypair"
 )
 
 // getAssetKeypairs generates a pair of keypairs (seed+address), issuing and distribution for issuing an asset
+// If a keystore is set (flag flgKeystore) the keypairs are the ones stored with the labels asset/issuing and asset/distribution (random keys added the first time).
 func getAssetKeypairs() (pairIss, pairDis *keypair.Full, err error) {
+	if ks := colon.DefaultKeystore(); ks != nil {
+		if pairIss, err = keystoreKeypair(ks, "asset/issuing"); err != nil {
+			return pairIss, pairDis, err
+		}
+		pairDis, err = keystoreKeypair(ks, "asset/distribution")
+		return pairIss, pairDis, err
+	}
+
 	// generate a [32]byte seed for the issuing account
 	bytesIss := []byte("issuing account byteseed that can be printed")
 	byteSeedIss := [32]byte{}
@@ -41,27 +51,34 @@ func getAssetKeypairs() (pairIss, pairDis *keypair.Full, err error) {
 	return pairIss, pairDis, err
 }
 
+// keystoreKeypair returns the keypair stored with the label, if there is none it adds a random one.
+func keystoreKeypair(ks *colon.Keystore, label string) (pair *keypair.Full, err error) {
+	for _, e := range ks.List() {
+		if e.Label == label {
+			return ks.Export(label)
+		}
+	}
+	if pair, err = keypair.Random(); err != nil {
+		return pair, err
+	}
+	return pair, ks.Add(label, pair)
+}
+
 func fundAddress(addr string) (err error) {
-	// ask balance to the faucet bot
+	// ask balance to the faucet bot, if the account already exists it is not funded again
 	log.Printf("Requesting funding for Address %s\n", addr)
-	resp, err := http.Get("https://friendbot.stellar.org/?addr=" + addr)
-	if err != nil {
-		return err
-	}
-	// check the response
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err == nil {
-		fmt.Println(body)
+	funded, err := colon.MEnsureFunded(addr)
+	if err == nil && !funded {
+		log.Printf("Address %s already exists\n", addr)
 	}
 	return err
 }
 
 func balAddress(addr string) (err error) {
-	account, err := colon.MLoadAccount(addr)
+	bals, err := colon.MLoadBalances(addr)
 	if err == nil {
-		for _, balance := range account.Balances {
-			fmt.Println("Addr", addr, balance)
+		for asset, b := range bals {
+			fmt.Println("Addr", addr, asset, "balance", b.Balance, "limit", b.Limit, "authorized", b.Authorized, "available", b.Available)
 		}
 	}
 	return err
@@ -81,6 +98,25 @@ func TestAssetAddrs(t *testing.T) {
 	log.Printf("Distribution keypair Seed %s, Address %s\n", seedDis, addrDis)
 }
 
+// TestAssetFixture provisions the accounts, flags and trustline of fixtures/asset.json if they have not been provisioned or the test network has been reset.
+func TestAssetFixture(t *testing.T) {
+	// get the issuing and distribution keypairs
+	pairIss, pairDis, err := getAssetKeypairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fx, err := colon.LoadFixture("fixtures/asset.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers := map[string]colon.Signer{"issuing": pairIss, "distribution": pairDis}
+	provisioned, err := colon.MEnsureFixture(fx, signers, filepath.Join(os.TempDir(), "colon-asset-mark.json"))
+	if err != nil {
+		t.Error(err)
+	}
+	fmt.Println("asset fixture provisioned", provisioned)
+}
+
 func TestAssetFund(t *testing.T) {
 	// get the issuing and distribution keypairs
 	pairIss, pairDis, err := getAssetKeypairs()
@@ -112,15 +148,68 @@ func TestAssetBal(t *testing.T) {
 
 }
 
+func TestAssetBalVEF(t *testing.T) {
+	// get the issuing and distribution keypairs
+	pairIss, pairDis, err := getAssetKeypairs()
+	if err != nil {
+		t.Error(err)
+	}
+	// get the VEF balance of the distribution account
+	bal, err := colon.MBalanceOf(pairDis.Address(), colon.Asset{Code: "VEF", Issuer: pairIss.Address()})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	fmt.Println("Addr", pairDis.Address(), bal.Asset, "balance", bal.Balance, "limit", bal.Limit, "authorized", bal.Authorized)
+}
+
+func TestAssetReserve(t *testing.T) {
+	// get the issuing and distribution keypairs
+	pairIss, pairDis, err := getAssetKeypairs()
+	if err != nil {
+		t.Error(err)
+	}
+	// current reserve of the distribution account
+	account, err := colon.MLoadAccount(pairDis.Address())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	baseReserve, err := colon.MBaseReserve()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	r, err := colon.MReserve(account, baseReserve)
+	fmt.Println("Reserve", pairDis.Address(), r, err)
+
+	// reserve after creating a trustline to a new asset (EUR)
+	tb, err := colon.MTrans(pairDis.Address(), build.Trust("EUR", pairIss.Address(), build.Limit("200")))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	r, err = colon.MReservePredict(account, baseReserve, tb)
+	fmt.Println("Reserve after EUR trustline", pairDis.Address(), r, err)
+}
+
 func TestAssetTrust(t *testing.T) {
 	// get the issuing and distribution keypairs
 	pairIss, pairDis, err := getAssetKeypairs()
 	if err != nil {
 		t.Error(err)
 	}
-	if err = colon.MTransTrust(pairDis, "VEF", pairIss.Address(), "1500", true); err != nil {
+	// it is skipped if the trustline already has the limit, so the drill can be run again
+	receipt, skipped, err := colon.MEnsureTrust(pairDis, "VEF", pairIss.Address(), colon.MustParseAmount("1500"), true)
+	if err != nil {
 		t.Error(err)
+		return
+	}
+	if skipped {
+		fmt.Println("..already set")
+		return
 	}
+	fmt.Println("..successful", "Ledger", receipt.Ledger, "Hash", receipt.Hash, "Fee", receipt.FeeCharged)
 }
 
 func TestTransPayXLM(t *testing.T) {
@@ -130,9 +219,12 @@ func TestTransPayXLM(t *testing.T) {
 		t.Error(err)
 	}
 	_ = pairIss
-	if err = colon.MTransPayment(pairDis, "GBUAFDIXDT4EOPLAJN7CWVXSHRN3KH2ASKRNMCIIIMXOO4QYWFIHMBEG", "", "0.1", true); err != nil {
+	receipt, err := colon.MTransPayment(pairDis, "GBUAFDIXDT4EOPLAJN7CWVXSHRN3KH2ASKRNMCIIIMXOO4QYWFIHMBEG", "", colon.MustParseAmount("0.1"), true)
+	if err != nil {
 		t.Error(err)
+		return
 	}
+	fmt.Println("..successful", "Ledger", receipt.Ledger, "Hash", receipt.Hash, "Fee", receipt.FeeCharged)
 }
 
 func TestTransPayAsset(t *testing.T) {
@@ -141,7 +233,31 @@ func TestTransPayAsset(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if err = colon.MTransPayment(pairIss, pairDis.Address(), "VEF", "1500", true); err != nil {
+	receipt, err := colon.MTransPayment(pairIss, pairDis.Address(), "VEF", colon.MustParseAmount("1500"), true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	fmt.Println("..successful", "Ledger", receipt.Ledger, "Hash", receipt.Hash, "Fee", receipt.FeeCharged)
+}
+
+func TestTransBatchPay(t *testing.T) {
+	// get the issuing and distribution keypairs
+	pairIss, pairDis, err := getAssetKeypairs()
+	if err != nil {
+		t.Error(err)
+	}
+	// send 3 payments in a single transaction, the last row is skipped because the destination does not exist
+	rows := []colon.PaymentRow{
+		{Dest: pairDis.Address(), Asset: "VEF", Amount: colon.One, Memo: "batch"},
+		{Dest: pairDis.Address(), Asset: "", Amount: colon.MustParseAmount("0.1"), Memo: "batch"},
+		{Dest: colon.DeterministicKeypair("NoExist").Address(), Asset: "VEF", Amount: colon.One, Memo: "batch"},
+	}
+	report, err := colon.MBatchPayment(pairIss, rows)
+	for i, s := range report {
+		fmt.Println("Row", i, s.Status, "Hash", s.Hash, "txCode", s.TxCode, "opCode", s.OpCode)
+	}
+	if err != nil {
 		t.Error(err)
 	}
 }
@@ -155,10 +271,27 @@ func TestTransPayErr(t *testing.T) {
 
 	// send 1 VEF asset from account A (issuer) to account B (distributor); as there is no trust gives transaction:"tx_failed", operations:["op_no_trust"]
 	fmt.Printf("Send 1 VEF from A %s to B %s\n", pair_A.Address(), pair_B.Address())
-	err := colon.MTransPayment(pair_A, pair_B.Address(), "VEF", "1", false)
+	_, err := colon.MTransPayment(pair_A, pair_B.Address(), "VEF", colon.One, false)
+	colontest.ExpectTxCodes(t, err, colon.TxFailed, colon.OpNoTrust)
+}
+
+func TestTransPreflight(t *testing.T) {
+	pair_A := colon.DeterministicKeypair("A")
+	pair_B := colon.DeterministicKeypair("B")
+
+	// build (without sending) a payment of 1 VEF from account A (issuer) to account B and predict its failures (op_no_trust if B has no trustline)
+	tb, err := colon.MTrans(pair_A.Address(), build.Payment(build.Destination{pair_B.Address()}, build.CreditAmount{"VEF", pair_A.Address(), "1"}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	findings, err := colon.MPreflight(tb)
 	if err != nil {
 		t.Error(err)
 	}
+	for _, f := range findings {
+		fmt.Println("Finding", "op", f.Op, "code", f.Code, f.Detail)
+	}
 }
 
 func TestTransAllowTrust1(t *testing.T) {
@@ -167,9 +300,17 @@ func TestTransAllowTrust1(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if err = colon.MAllowTrust(pairIss, "VEF", pairDis.Address(), true, false); err != nil {
+	// it is skipped if the trustline is already authorized, so the drill can be run again
+	receipt, skipped, err := colon.MEnsureAllowTrust(pairIss, "VEF", pairDis.Address(), true, false)
+	if err != nil {
 		t.Error(err)
+		return
 	}
+	if skipped {
+		fmt.Println("..already set")
+		return
+	}
+	fmt.Println("..successful", "Ledger", receipt.Ledger, "Hash", receipt.Hash, "Fee", receipt.FeeCharged)
 }
 
 func TestTransAllowTrust2(t *testing.T) {
@@ -184,7 +325,7 @@ func TestTransAllowTrust2(t *testing.T) {
 		opMut := build.AllowTrust(build.Trustor{pairDis.Address()}, build.AllowTrustAsset{Code: "VEF"}, build.Authorize{Value: false})
 		if err = colon.MOpsAdd(tb, opMut); err == nil {
 			// sign the transaction
-			if txe, err2 := colon.MSign(tb, pairIss.Seed()); err2 == nil {
+			if txe, err2 := colon.MSign(tb, pairIss); err2 == nil {
 				// submit the transaction
 				fmt.Println("AllowTrust Transaction", "VEF", "from", pairIss.Address(), "to", pairDis.Address(), "baseFee", tb.BaseFee)
 				if resp, err3 := colon.MSubmit(txe); err3 == nil {
@@ -216,7 +357,7 @@ func TestTransAllowTrust3(t *testing.T) {
 	opMut := build.AllowTrust(build.Trustor{pairDis.Address()}, build.AllowTrustAsset{Code: "VEF"}, build.Authorize{Value: false})
 	if tb, err1 := colon.MTrans(pairIss.Address(), opMut); err1 == nil {
 		// sign the transaction
-		if txe, err2 := colon.MSign(tb, pairIss.Seed()); err2 == nil {
+		if txe, err2 := colon.MSign(tb, pairIss); err2 == nil {
 			// submit the transaction
 			fmt.Println("AllowTrust Transaction", "VEF", "from", pairIss.Address(), "to", pairDis.Address(), "baseFee", tb.BaseFee)
 			if resp, err3 := colon.MSubmit(txe); err3 == nil {
@@ -245,9 +386,12 @@ func TestTransSetOptions(t *testing.T) {
 	}
 	//if err = colon.MSetOptions(pairIss, map[string]interface{}{"SetFlags": uint32(0x01 | 0x02 | 0x04)}); err != nil { // authReq+authRev+authImm
 	//if err = colon.MSetOptions(pairIss, map[string]interface{}{"ClearFlags": uint32(0x02 | 0x04)}); err != nil { // authRev+authImm
-	if err = colon.MSetOptions(pairIss, map[string]interface{}{"HomeDomain": "subdomain.domain.com"}); err != nil { // authRev+authImm
+	receipt, err := colon.MSetOptions(pairIss, map[string]interface{}{"HomeDomain": "subdomain.domain.com"}) // authRev+authImm
+	if err != nil {
 		t.Error(err)
+		return
 	}
+	fmt.Println("..successful", "Ledger", receipt.Ledger, "Hash", receipt.Hash, "Fee", receipt.FeeCharged, "txCode", receipt.TxCode, "opCodes", receipt.OpCodes)
 }
 
 func TestTransUnmarshal(t *testing.T) {
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/stellar/go/keypair"
+)
+
+// TestDistribute distributes VEF (issued by the asset test issuing account) to the addresses of the flgCSV file.
+// By default it is a dry-run that only shows the summary, to send the payments type in the terminal
+//
+//	go test -run TestDistribute -flgCSV=airdrop.csv -flgExec
+//
+// The results are written to flgCSV+".results.csv"; if the distribution is interrupted run the same command again and the rows already paid are skipped.
+func TestDistribute(t *testing.T) {
+	// get the issuing keypair
+	pairIss, _, err := getAssetKeypairs()
+	if err != nil {
+		t.Error(err)
+	}
+	// read and validate the rows
+	rows, err := colon.MDistReadCSV(*flgCSV, "VEF")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sum, err := colon.MDistCheck(pairIss, rows)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	colon.MDistPrintSummary(os.Stdout, rows, sum)
+	if !*flgExec {
+		return
+	}
+	// send the valid rows
+	if err = colon.MDistribute(pairIss, rows, *flgCSV+".results.csv"); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestDistributeResume resumes a distribution interrupted while submitting a transaction that was applied: the rows are marked paid and not sent again.
+func TestDistributeResume(t *testing.T) {
+	// a mock horizon that has the transaction being submitted
+	const addr, hash = "GAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "aaaa0000000000000000000000000000000000000000000000000000000000aa"
+	defer mockHorizon(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/transactions/"+hash {
+			fmt.Fprint(w, `{"hash": "`+hash+`", "ledger": 7, "successful": true}`)
+			return
+		}
+		t.Error("unexpected horizon request", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	})()
+
+	// the results of the interrupted run: line 2 paid, lines 3 and 4 being submitted
+	dir, err := ioutil.TempDir("", "colon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	results := filepath.Join(dir, "results.csv")
+	prevResults := "line,address,amount,memo,status,hash,tx_code,op_code,seq\n" +
+		"2," + addr + ",10,,paid,bbbb,,,0\n" +
+		"3," + addr + ",20,,submitting," + hash + ",,,12\n" +
+		"4," + addr + ",30,,submitting," + hash + ",,,12\n"
+	if err = ioutil.WriteFile(results, []byte(prevResults), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rows := []colon.DistRow{
+		{PaymentRow: colon.PaymentRow{Dest: addr, Asset: "VEF", Amount: 10 * colon.One}, Line: 2},
+		{PaymentRow: colon.PaymentRow{Dest: addr, Asset: "VEF", Amount: 20 * colon.One}, Line: 3},
+		{PaymentRow: colon.PaymentRow{Dest: addr, Asset: "VEF", Amount: 30 * colon.One}, Line: 4},
+	}
+	pairIss, _ := keypair.Random()
+	if err = colon.MDistribute(pairIss, rows, results); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added := strings.TrimPrefix(string(data), prevResults)
+	for _, line := range []string{"3", "4"} {
+		if !strings.Contains(added, line+","+addr+",") || !strings.Contains(added, ",paid,"+hash+",") {
+			t.Errorf("line %s not marked paid: %q", line, added)
+		}
+	}
+	if strings.Count(added, "\n") != 2 {
+		t.Errorf("expected 2 new results, got %q", added)
+	}
+}
+
+// TestBatchPaymentChecks checks the rows before sending: the ones that would fail are skipped and a destination that cannot be loaded stops the batch.
+func TestBatchPaymentChecks(t *testing.T) {
+	pairIss, _ := keypair.Random()
+	noTrust, _ := keypair.Random()
+	missing, _ := keypair.Random()
+	broken, _ := keypair.Random()
+	defer mockHorizon(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/accounts/" + noTrust.Address():
+			fmt.Fprint(w, `{"id": "`+noTrust.Address()+`", "account_id": "`+noTrust.Address()+`", "sequence": "1", "balances": [{"balance": "10.0000000", "asset_type": "native"}]}`)
+		case "/accounts/" + broken.Address():
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			if r.Method == "POST" {
+				t.Error("a transaction was sent")
+			}
+			notFound(w)
+		}
+	})()
+
+	// an account not found, an account without trustline and a memo too long are skipped
+	rows := []colon.PaymentRow{
+		{Dest: missing.Address(), Amount: colon.One},
+		{Dest: noTrust.Address(), Asset: "VEF", Amount: colon.One},
+		{Dest: noTrust.Address(), Amount: colon.One, Memo: strings.Repeat("m", colon.MaxMemoText+1)},
+	}
+	report, err := colon.MBatchPayment(pairIss, rows)
+	if err != nil || len(report) != 3 {
+		t.Fatal(report, err)
+	}
+	for i, code := range []colon.ResultCode{colon.OpNoDestination, colon.OpNoTrust, ""} {
+		if report[i].Status != colon.RowSkipped || report[i].OpCode != code || (code == "") != (report[i].Err != nil) {
+			t.Errorf("row %d: %+v, expected skipped %s", i, report[i], code)
+		}
+	}
+
+	// an error loading a destination is returned and nothing is sent
+	if _, err = colon.MBatchPayment(pairIss, append(rows, colon.PaymentRow{Dest: broken.Address(), Amount: colon.One})); err == nil {
+		t.Error("no error for a destination that cannot be loaded")
+	}
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/8manuel/colongo/colon"
+)
+
+func TestAmountParse(t *testing.T) {
+	// parse valid amounts and format them again with 7 decimals
+	for s, want := range map[string]string{
+		"96":                   "96.0000000",
+		"0.1":                  "0.1000000",
+		".5":                   "0.5000000",
+		"1500.0000001":         "1500.0000001",
+		"922337203685.4775807": "922337203685.4775807",
+	} {
+		a, err := colon.ParseAmount(s)
+		if err != nil {
+			t.Error(s, err)
+		} else if a.String() != want {
+			t.Error(s, "formatted as", a.String(), "want", want)
+		}
+	}
+	// parse invalid amounts
+	for _, s := range []string{"", ".", "1,5", "0.00000001", "1e3", "+1", "-4", "-0.5", "922337203685.4775808"} {
+		if a, err := colon.ParseAmount(s); err == nil {
+			t.Error(s, "parsed as", a)
+		}
+	}
+}
+
+func TestAmountNegative(t *testing.T) {
+	// negative amounts are rejected before sending anything
+	if _, err := colon.ParseAmount("-5"); err == nil {
+		t.Error("-5 parsed")
+	}
+	if _, err := colon.MTransPayment(nil, "GBUAFDIXDT4EOPLAJN7CWVXSHRN3KH2ASKRNMCIIIMXOO4QYWFIHMBEG", "", -5*colon.One, false); err == nil {
+		t.Error("payment of -5 accepted")
+	}
+	if _, err := colon.MTransTrust(nil, "VEF", "GBUAFDIXDT4EOPLAJN7CWVXSHRN3KH2ASKRNMCIIIMXOO4QYWFIHMBEG", -5*colon.One, false); err == nil {
+		t.Error("trust limit of -5 accepted")
+	}
+}
+
+func TestAmountArithmetic(t *testing.T) {
+	// acc B balance 100 VEF, sends 4 VEF
+	bal := colon.MustParseAmount("100")
+	bal, err := bal.Sub(colon.MustParseAmount("4"))
+	if err != nil || bal.Cmp(colon.MustParseAmount("96")) != 0 {
+		t.Error("100-4 =", bal, err)
+	}
+	if x, err := bal.Mul(3); err != nil || x.String() != "288.0000000" {
+		t.Error("96*3 =", x, err)
+	}
+	// overflows
+	max := colon.MustParseAmount("922337203685.4775807")
+	if _, err := max.Add(1); err != colon.ErrAmountOverflow {
+		t.Error("max+1 does not overflow")
+	}
+	if _, err := (-max).Sub(2); err != colon.ErrAmountOverflow {
+		t.Error("-max-2 does not overflow")
+	}
+	if _, err := max.Mul(2); err != colon.ErrAmountOverflow {
+		t.Error("max*2 does not overflow")
+	}
+}
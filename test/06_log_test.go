@@ -0,0 +1,59 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/8manuel/colongo/colon"
+)
+
+func TestLogRedactSeed(t *testing.T) {
+	// log to a buffer and restore the tests logger at the end
+	var buf bytes.Buffer
+	colon.SetLogger(colon.NewWriterLogger(&buf, colon.LevelDebug))
+	defer colon.SetLogger(colon.NewWriterLogger(os.Stdout, colon.LevelInfo))
+
+	// an error that contains a seed is logged without the seed
+	seed := "SDNYODGEMGKGIBNCR6C6XYQ7LUH5CIL2MNNIDTQQPWO6XNTIAVRHF43P"
+	colon.MHorizonProblemView(errors.New("bad seed " + seed))
+	if strings.Contains(buf.String(), seed) {
+		t.Error("seed logged:", buf.String())
+	}
+	if !strings.Contains(buf.String(), "<redacted>") {
+		t.Error("seed not redacted:", buf.String())
+	}
+}
+
+func TestLogQuiet(t *testing.T) {
+	defer colon.SetLogger(colon.NewWriterLogger(os.Stdout, colon.LevelInfo))
+
+	// the silent logger (nil) does not write anything, not even to stdout or stderr
+	stdout, stderr := os.Stdout, os.Stderr
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout, os.Stderr = w, w
+	colon.SetLogger(nil)
+	colon.MHorizonProblemView(errors.New("not shown"))
+	os.Stdout, os.Stderr = stdout, stderr
+	w.Close()
+	var quiet bytes.Buffer
+	quiet.ReadFrom(r)
+	if quiet.Len() > 0 {
+		t.Errorf("quiet logger wrote %q", quiet.String())
+	}
+
+	// the writer logger at the warn and error levels writes the error
+	for _, level := range []colon.Level{colon.LevelWarn, colon.LevelError} {
+		var buf bytes.Buffer
+		colon.SetLogger(colon.NewWriterLogger(&buf, level))
+		colon.MHorizonProblemView(errors.New("shown"))
+		if !strings.Contains(buf.String(), "ERROR") || !strings.Contains(buf.String(), "shown") {
+			t.Errorf("%s logger wrote %q", level, buf.String())
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/stellar/go/keypair"
+)
+
+func TestKeystore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keys.json")
+
+	// create the keystore and add two keys
+	ks, err := colon.CreateKeystore(path, "drill password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairA, _ := keypair.Random()
+	pairB, _ := keypair.Random()
+	if err = ks.Add("course/A", pairA); err != nil {
+		t.Fatal(err)
+	}
+	if err = ks.Add("course/B", pairB); err != nil {
+		t.Fatal(err)
+	}
+	if err = ks.Add("course/A", pairB); err == nil {
+		t.Error("no error adding a repeated label")
+	}
+
+	// the seeds are not in the file, the addresses are
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), pairA.Seed()) || !strings.Contains(string(data), pairA.Address()) {
+		t.Error("seed in cleartext or address missing:", string(data))
+	}
+
+	// a wrong password is detected
+	if _, err = colon.OpenKeystore(path, "wrong password"); err != colon.ErrWrongPassword {
+		t.Error("expected ErrWrongPassword, got", err)
+	}
+
+	// reopen, list, export and remove
+	if ks, err = colon.OpenKeystore(path, "drill password"); err != nil {
+		t.Fatal(err)
+	}
+	if entries := ks.List(); len(entries) != 2 || entries[0].Label != "course/A" || entries[1].Address != pairB.Address() {
+		t.Error("wrong entries", entries)
+	}
+	if pair, err := ks.Export("course/A"); err != nil || pair.Seed() != pairA.Seed() {
+		t.Error("wrong exported key", err)
+	}
+	if err = ks.Remove("course/A"); err != nil {
+		t.Error(err)
+	}
+	if _, err = ks.Export("course/A"); err == nil {
+		t.Error("no error exporting a removed label")
+	}
+
+	// the signing helpers get the keys by label from the package keystore
+	defer colon.SetDefaultKeystore(colon.DefaultKeystore())
+	colon.SetDefaultKeystore(ks)
+	if pair, err := colon.MKey("course/B"); err != nil || pair.Address() != pairB.Address() {
+		t.Error("wrong key from the package keystore", err)
+	}
+}
+
+func TestKeystoreKDFLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keys.json")
+	if _, err = colon.CreateKeystore(path, "drill password"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a keystore with scrypt parameters out of the limits is rejected before deriving the key
+	for _, kdf := range []map[string]int{{"n": 1 << 30}, {"n": 30000}, {"r": 1000}, {"p": 100000}, {"n": 1 << 20, "r": 32}} {
+		var file map[string]interface{}
+		if err = json.Unmarshal(data, &file); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range kdf {
+			file["kdf"].(map[string]interface{})[k] = v
+		}
+		crafted, _ := json.Marshal(file)
+		if err = ioutil.WriteFile(path, crafted, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = colon.OpenKeystore(path, "drill password"); err == nil || err == colon.ErrWrongPassword {
+			t.Error(kdf, "accepted:", err)
+		}
+	}
+}
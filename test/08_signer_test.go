@@ -0,0 +1,70 @@
+package test
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/stellar/go/keypair"
+)
+
+func TestSignerSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the signer process keeps the keypair and listens in a unix socket
+	pair, _ := keypair.Random()
+	sock := filepath.Join(dir, "colon.sock")
+	l, err := colon.ListenSigner(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if st, err := os.Stat(sock); err != nil || st.Mode().Perm() != 0600 {
+		t.Error("socket permissions", st.Mode(), err)
+	}
+	// a directory accessible by other users is refused
+	if err = os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = colon.ListenSigner(filepath.Join(dir, "other.sock")); err == nil {
+		t.Error("no error for a socket directory accessible by other users")
+	}
+	token, err := colon.NewSignerToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = colon.ServeSigner(l, "", pair); err == nil {
+		t.Error("no error serving without a token")
+	}
+	go colon.ServeSigner(l, token, pair)
+
+	// the socket signer gets the same signature as the keypair
+	hash := sha256.Sum256([]byte("transaction"))
+	var s colon.Signer = colon.SocketSigner{Network: "unix", Socket: sock, Addr: pair.Address(), Token: token}
+	sig, err := s.Sign(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = pair.Verify(hash[:], sig); err != nil {
+		t.Error("wrong signature", err)
+	}
+
+	// a request without the token, an address without signer and a hash that is not 32 bytes are rejected
+	if _, err = (colon.SocketSigner{Network: "unix", Socket: sock, Addr: pair.Address(), Token: "guess"}).Sign(hash[:]); err == nil {
+		t.Error("no error for a wrong token")
+	}
+	other, _ := keypair.Random()
+	if _, err = (colon.SocketSigner{Network: "unix", Socket: sock, Addr: other.Address(), Token: token}).Sign(hash[:]); err == nil {
+		t.Error("no error for an address without signer")
+	}
+	if _, err = s.Sign([]byte("short")); err == nil {
+		t.Error("no error for a short hash")
+	}
+}
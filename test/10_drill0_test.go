@@ -7,6 +7,7 @@ import (
 // The objective of this drill is to practice issuing assets from issuer to distributor and to play with trust lines (distributor and holder) and allow trust (issuer).
 // The asset is issued by account A that acts as issuer, the code is VEF.
 // Just remember that for the drills we are using testnet and the VEF value is the same as monopoly notes (nothing, well in this case the real VEF value is near to 0).
+// The steps and expected results of this drill are also described in scenarios/drill0.json, TestScenarioDrill0 runs them with new accounts.
 
 // TestDrill0FundAB creates and funds accounts A and B
 func TestDrill0FundAB(t *testing.T) {
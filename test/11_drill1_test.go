@@ -7,6 +7,7 @@ import (
 // The objective of this drill is to practice multisignature; it is required to have completed drill0 because the same accounts and assets are used.
 // The asset is issued by account A that acts as issuer, the code is VEF.
 // Just remember that for the drills we are using testnet and the VEF value is the same as monopoly notes (nothing, well in this case the real VEF value is near to 0).
+// The steps and expected results of this drill are also described in scenarios/drill1.json, TestScenarioDrill1 runs them with new accounts.
 
 // TestDrill1Exchange; transacion with two operations, first accB sends 5XLM to accA, second accA sends 15VEF to accB
 func TestDrill1Exchange(t *testing.T) {
@@ -18,7 +19,7 @@ func TestDrill1Exchange(t *testing.T) {
 	// send transaction to horizon; now it should work
 
 	// now sign the same tx with accB and accA and accC seed
-	// send transaction to horizon; now it should work even if in the documents say that it fails when there are more transactions than required (I suppose is because accC has no operation and is ignored)
+	// send transaction to horizon; it fails with transaction:"tx_bad_auth_extra" because accC is not a signer of accA or accB, every signature must be used
 }
 
 // TestDrill1Multi; for accC authorize signatures of accA with weight 1 and accB with weight 1; also set accC threshold for mid to 2 and high to 3.
@@ -0,0 +1,183 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/8manuel/colongo/colon"
+)
+
+// The drills are also described as scenarios in test/scenarios, the runner executes them with new accounts (namespaced with the run time) and reports each step.
+//	go test -run TestScenarioDrill0
+
+func TestScenarioLoad(t *testing.T) {
+	// the drill scenarios are valid
+	for _, path := range []string{"scenarios/drill0.json", "scenarios/drill1.json"} {
+		if _, err := colon.LoadScenario(path); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// a step with an unknown account or action is detected before running
+	sc := &colon.Scenario{Name: "bad", Accounts: []string{"A"}, Steps: []colon.Step{{Action: colon.ActionPay, Account: "A", To: "Z", Amount: "1"}}}
+	if err := sc.Validate(); err == nil {
+		t.Error("no error for an unknown account")
+	}
+	sc.Steps = []colon.Step{{Action: "burn", Account: "A"}}
+	if err := sc.Validate(); err == nil {
+		t.Error("no error for an unknown action")
+	}
+}
+
+// runScenario runs the scenario file with new accounts and prints the result of each step.
+func runScenario(t *testing.T, path string) {
+	sc, err := colon.LoadScenario(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers, err := colon.MScenarioSigners(sc, nil, "scenario/"+strconv.FormatInt(time.Now().Unix(), 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := colon.MRunScenario(sc, signers)
+	for _, r := range results {
+		status := "PASS"
+		if !r.Pass {
+			status = "FAIL"
+		}
+		fmt.Println(status, "step", r.Step, r.Name, r.TxCode, r.OpCodes, r.Problems, r.Err)
+	}
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScenarioDrill0(t *testing.T) {
+	runScenario(t, "scenarios/drill0.json")
+}
+
+func TestScenarioDrill1(t *testing.T) {
+	runScenario(t, "scenarios/drill1.json")
+}
+
+func TestScenarioResume(t *testing.T) {
+	// a progress file of another scenario is not overwritten
+	sc, err := colon.LoadScenario("scenarios/drill0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers, err := colon.MScenarioSigners(sc, nil, "resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ioutil.TempFile("", "progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"scenario": "drill1", "accounts": {"A": "GA"}, "completed": 3}`)
+	f.Close()
+	if _, err = colon.MRunScenarioResume(sc, signers, f.Name()); err == nil {
+		t.Error("no error for the progress of another scenario")
+	}
+	if p, err := colon.LoadProgress(f.Name()); err != nil || p.Scenario != "drill1" || p.Completed != 3 {
+		t.Errorf("progress changed %v %v", p, err)
+	}
+
+	// a step submitted that failed is checked again but not sent again (the mock horizon has no accounts and no transactions)
+	var sent int32
+	defer mockHorizon(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			atomic.AddInt32(&sent, 1)
+		}
+		notFound(w)
+	})()
+	sc = &colon.Scenario{Name: "pay", Accounts: []string{"A", "B"}, Steps: []colon.Step{{Name: "pay", Action: colon.ActionPay, Account: "A", To: "B", Amount: "1"}}}
+	for _, c := range []struct {
+		txCode    colon.ResultCode
+		completed int
+	}{{colon.TxFailed, 0}, {colon.TxSuccess, 1}} {
+		p, _ := json.Marshal(colon.Progress{Scenario: "pay", Accounts: map[string]string{"A": signers["A"].Address(), "B": signers["B"].Address()}, Submitted: 1, SubmittedTx: c.txCode})
+		ioutil.WriteFile(f.Name(), p, 0644)
+		results, err := colon.MRunScenarioResume(sc, signers, f.Name())
+		if len(results) != 1 || results[0].Pass != (c.completed == 1) || (err == nil) != results[0].Pass {
+			t.Errorf("%s: results %v %v", c.txCode, results, err)
+		}
+		if p, err := colon.LoadProgress(f.Name()); err != nil || p.Completed != c.completed || p.Submitted != 1 {
+			t.Errorf("%s: progress %v %v", c.txCode, p, err)
+		}
+	}
+	if sent != 0 {
+		t.Errorf("%d transactions sent again", sent)
+	}
+}
+
+// TestScenarioDrill0Resume runs drill0 with the accounts resume/NAME, if a step fails running it again continues from that step.
+func TestScenarioDrill0Resume(t *testing.T) {
+	sc, err := colon.LoadScenario("scenarios/drill0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers, err := colon.MScenarioSigners(sc, nil, "resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := colon.MRunScenarioResume(sc, signers, filepath.Join(os.TempDir(), "colon-drill0-progress.json"))
+	for _, r := range results {
+		fmt.Println("step", r.Step, r.Name, r.Pass, r.TxCode, r.OpCodes, r.Problems, r.Err)
+	}
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScenarioEnsure(t *testing.T) {
+	// B already trusts the VEF of A and it is authorized, so the trust and allow steps are skipped without sending them
+	sc := &colon.Scenario{Name: "ensure", Accounts: []string{"A", "B"}, Assets: map[string]colon.ScenarioAsset{"VEF": {Code: "VEF", Issuer: "A"}},
+		Steps: []colon.Step{
+			{Name: "trust", Action: colon.ActionTrust, Account: "B", Asset: "VEF", Amount: "500"},
+			{Name: "allow", Action: colon.ActionAllow, Account: "A", Trustor: "B", Asset: "VEF"},
+		}}
+	signers, err := colon.MScenarioSigners(sc, nil, "ensure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrA, addrB := signers["A"].Address(), signers["B"].Address()
+	var sent int32
+	defer mockHorizon(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			atomic.AddInt32(&sent, 1)
+			w.WriteHeader(http.StatusBadRequest)
+		case r.URL.Path == "/ledgers":
+			fmt.Fprint(w, `{"_embedded": {"records": [{"base_reserve_in_stroops": 5000000}]}}`)
+		case r.URL.Path == "/accounts/"+addrB:
+			fmt.Fprint(w, `{"id": "`+addrB+`", "account_id": "`+addrB+`", "sequence": "1", "subentry_count": 1, "balances": [
+				{"balance": "100.0000000", "asset_type": "native"},
+				{"balance": "0.0000000", "limit": "500.0000000", "is_authorized": true, "asset_type": "credit_alphanum4", "asset_code": "VEF", "asset_issuer": "`+addrA+`"}]}`)
+		default:
+			notFound(w)
+		}
+	})()
+
+	results, err := colon.MRunScenario(sc, signers)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, r := range results {
+		if !r.Pass || !r.Skipped || r.TxCode != colon.TxSuccess {
+			t.Errorf("step %s: %+v", r.Name, r)
+		}
+	}
+	if sent != 0 {
+		t.Errorf("%d transactions sent", sent)
+	}
+}
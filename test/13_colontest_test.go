@@ -0,0 +1,67 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/8manuel/colongo/colon/colontest"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// recordT records the failures of the colontest helpers instead of failing the test.
+type recordT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordT) Helper() {}
+
+func (r *recordT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// failedTx returns the horizon error of a failed transaction with the result codes.
+func failedTx(txCode string, opCodes ...string) error {
+	codes, _ := json.Marshal(map[string]interface{}{"transaction": txCode, "operations": opCodes})
+	return &horizon.Error{Problem: horizon.Problem{Type: "transaction_failed", Status: 400, Extras: map[string]json.RawMessage{"result_codes": codes}}}
+}
+
+func TestColontestExpectTxCodes(t *testing.T) {
+	cases := []struct {
+		err    error
+		txCode colon.ResultCode
+		ops    []colon.ResultCode
+		pass   bool
+	}{
+		{failedTx("tx_failed", "op_no_trust"), "tx_failed", []colon.ResultCode{"op_no_trust"}, true},
+		{failedTx("tx_failed", "op_no_trust"), colon.TxFailed, nil, true},
+		{failedTx("tx_failed", "op_no_trust"), colon.TxFailed, []colon.ResultCode{colon.OpNotAuthorized}, false},
+		{failedTx("tx_bad_auth_extra"), colon.TxBadAuthExtra, nil, true},
+		{failedTx("tx_failed", "op_success", "op_bad_auth"), colon.TxFailed, []colon.ResultCode{colon.OpSuccess, colon.OpBadAuth}, true},
+		{nil, colon.TxSuccess, nil, true},
+		{nil, colon.TxFailed, []colon.ResultCode{colon.OpNoTrust}, false},
+		{fmt.Errorf("connection refused"), colon.TxFailed, nil, false},
+	}
+	for i, c := range cases {
+		r := &recordT{TB: t}
+		if pass := colontest.ExpectTxCodes(r, c.err, c.txCode, c.ops...); pass != c.pass || pass != (len(r.errors) == 0) {
+			t.Errorf("case %d: pass %v, expected %v, errors %v", i, pass, c.pass, r.errors)
+		}
+	}
+}
+
+func TestHorizonErrorResultCode(t *testing.T) {
+	// the string codes are the same as the result codes of MErrorCodes
+	txCode, opCodes, err := colon.MHorizonErrorResultCode(failedTx("tx_failed", "op_success", "op_no_trust"))
+	if err != nil || txCode != "tx_failed" || len(opCodes) != 2 || opCodes[0] != "op_success" || opCodes[1] != "op_no_trust" {
+		t.Errorf("got %s %v %v", txCode, opCodes, err)
+	}
+	if _, _, err = colon.MHorizonErrorResultCode(fmt.Errorf("connection refused")); err == nil {
+		t.Error("no error for an error that is not a horizon.Error")
+	}
+	if !colon.MSameCodes([]colon.ResultCode{colon.OpSuccess}, []colon.ResultCode{"op_success"}) || colon.MSameCodes([]colon.ResultCode{colon.OpSuccess}, nil) {
+		t.Error("MSameCodes")
+	}
+}
@@ -0,0 +1,135 @@
+package test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/8manuel/colongo/colon"
+)
+
+// A classroom gives each student a base seed, the students run the drills with their own accounts:
+//	go test -run TestDrill0FundAB flgBaseSeed=STUDENTSEED flgSalt=CLASSROOMSALT
+
+func TestClassroom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "colon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "classroom.json")
+
+	// create the classroom and add students, each one with its own base seed
+	c, err := colon.CreateClassroom(path, "course2018")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = colon.CreateClassroom(path, "course2018"); err == nil {
+		t.Error("no error creating an existing classroom")
+	}
+	students, err := c.AddStudents("ana", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(students) != 2 || len(students[0].BaseSeed) != 24 || students[0].BaseSeed == students[1].BaseSeed {
+		t.Errorf("wrong student base seeds %v", students)
+	}
+	if _, err = c.AddStudents("carl", "ana"); err == nil {
+		t.Error("no error adding an existing student")
+	}
+	if _, err = c.AddStudents("bad/"); err == nil {
+		t.Error("no error adding a wrong student name")
+	}
+
+	// the students and the salt are in the file
+	c, err = colon.OpenClassroom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Students(); len(got) != 2 || got[0] != students[0] || got[1] != students[1] {
+		t.Errorf("students %v, expected %v", got, students)
+	}
+	if c.Name() != "course2018" || len(c.Salt()) <= len("course2018-") {
+		t.Errorf("wrong name %s or salt %s", c.Name(), c.Salt())
+	}
+	if _, err = c.Student("carl"); err == nil {
+		t.Error("no error for an unknown student")
+	}
+
+	// the dashboard with a mock horizon where no account exists
+	type gate struct{ entered, release chan struct{} }
+	var requests int32
+	var blocked atomic.Value
+	blocked.Store(gate{})
+	defer mockHorizon(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if g := blocked.Load().(gate); g.release != nil {
+			select {
+			case g.entered <- struct{}{}:
+			default:
+			}
+			<-g.release
+		}
+		notFound(w)
+	})()
+	sc, err := colon.LoadScenario("scenarios/drill0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := colon.NewDashboard(c, sc)
+	for _, page := range []string{"/", "/status.json"} {
+		rec := httptest.NewRecorder()
+		d.ServeHTTP(rec, httptest.NewRequest("GET", page, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s status %d", page, rec.Code)
+		}
+		if page == "/" {
+			continue
+		}
+		var status struct {
+			Classroom string
+			Students  []struct {
+				Student  string
+				Accounts []struct{ Exists bool }
+			}
+		}
+		if err = json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		if status.Classroom != "course2018" || len(status.Students) != 2 || status.Students[0].Student != "ana" || len(status.Students[0].Accounts) != 3 {
+			t.Errorf("wrong status %s", rec.Body.String())
+		}
+	}
+	// while a reload runs (blocked in horizon) the status is the previous one, a reload just after a load is ignored
+	d = colon.NewDashboard(c, sc)
+	_, loaded := d.Status(false)
+	entered, release := make(chan struct{}, 1), make(chan struct{})
+	blocked.Store(gate{entered, release})
+	d.MinReload = 0
+	done := make(chan time.Time)
+	go func() {
+		_, reloaded := d.Status(true)
+		done <- reloaded
+	}()
+	<-entered
+	blocked.Store(gate{})
+	if _, stale := d.Status(true); !stale.Equal(loaded) {
+		t.Error("status loaded during a load")
+	}
+	close(release)
+	reloaded := <-done
+	if reloaded.Equal(loaded) {
+		t.Error("status not reloaded")
+	}
+	d.MinReload = time.Minute
+	atomic.StoreInt32(&requests, 0)
+	if _, again := d.Status(true); !again.Equal(reloaded) || atomic.LoadInt32(&requests) != 0 {
+		t.Errorf("status reloaded just after a load, %d requests", atomic.LoadInt32(&requests))
+	}
+}
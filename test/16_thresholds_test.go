@@ -0,0 +1,55 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+)
+
+func TestSignatureCheck(t *testing.T) {
+	// account A has thresholds low 1, med 2, high 3 and the signers A (master) and B with weight 1
+	pairA, _ := keypair.Random()
+	pairB, _ := keypair.Random()
+	defer mockHorizon(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/accounts/"+pairA.Address()) {
+			notFound(w)
+			return
+		}
+		fmt.Fprint(w, `{"id": "`+pairA.Address()+`", "account_id": "`+pairA.Address()+`", "sequence": "100", "balances": [],
+			"thresholds": {"low_threshold": 1, "med_threshold": 2, "high_threshold": 3},
+			"signers": [{"public_key": "`+pairA.Address()+`", "key": "`+pairA.Address()+`", "weight": 1, "type": "ed25519_public_key"},
+				{"public_key": "`+pairB.Address()+`", "key": "`+pairB.Address()+`", "weight": 1, "type": "ed25519_public_key"}]}`)
+	})()
+
+	check := func(name string, level colon.ThresholdLevel, ok bool, muts []build.TransactionMutator, signers ...colon.Signer) {
+		tb, err := colon.MTrans(pairA.Address(), muts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txe, err := colon.MSign(tb, signers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checks, err := colon.MSignatureCheck(*txe.E)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(checks) != 1 || checks[0].Level != level || checks[0].OK != ok {
+			t.Errorf("%s: checks %+v, expected level %s ok %v", name, checks, level, ok)
+		}
+	}
+	payment := []build.TransactionMutator{build.Payment(build.Destination{pairB.Address()}, build.NativeAmount{"1"})}
+	options := []build.TransactionMutator{build.SetOptions(build.AddSigner(pairB.Address(), 2))}
+	check("payment signed by A", colon.ThresholdMed, false, payment, pairA)
+	check("payment signed by A and B", colon.ThresholdMed, true, payment, pairA, pairB)
+	check("signer signed by A and B", colon.ThresholdHigh, false, options, pairA, pairB)
+	allow := []build.TransactionMutator{build.AllowTrust(build.Trustor{pairB.Address()}, build.AllowTrustAsset{Code: "VEF"}, build.Authorize{Value: true})}
+	check("allow trust signed by B", colon.ThresholdLow, true, allow, pairB)
+	bump := []build.TransactionMutator{build.BumpSequence(build.BumpTo(200))}
+	check("bump sequence signed by B", colon.ThresholdLow, true, bump, pairB)
+}
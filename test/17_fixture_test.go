@@ -0,0 +1,60 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/8manuel/colongo/colon"
+)
+
+func TestFixtureLoad(t *testing.T) {
+	// the asset fixture is valid
+	if _, err := colon.LoadFixture("fixtures/asset.json"); err != nil {
+		t.Error(err)
+	}
+
+	// an unknown account, asset or flag is detected before provisioning
+	for _, fx := range []*colon.Fixture{
+		{Name: "bad", Accounts: []string{"A"}, Trustlines: []colon.FixtureTrustline{{Account: "A", Asset: "VEF"}}},
+		{Name: "bad", Accounts: []string{"A"}, Options: []colon.FixtureOptions{{Account: "A", SetFlags: []string{"auth_immutable"}}}},
+		{Name: "bad", Accounts: []string{"A"}, Assets: map[string]colon.ScenarioAsset{"VEF": {Code: "VEF", Issuer: "A"}},
+			Balances: []colon.FixtureBalance{{Account: "B", Asset: "VEF", Amount: "1"}}},
+	} {
+		if err := fx.Validate(); err == nil {
+			t.Errorf("no error for %+v", fx)
+		}
+	}
+}
+
+func TestDetectReset(t *testing.T) {
+	// a mock horizon at ledger 500 where only the account GEXISTS exists
+	defer mockHorizon(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/ledgers":
+			fmt.Fprint(w, `{"_embedded": {"records": [{"sequence": 500}]}}`)
+		case strings.HasPrefix(r.URL.Path, "/accounts/GEXISTS"):
+			fmt.Fprint(w, `{"id": "GEXISTS", "account_id": "GEXISTS", "sequence": "1", "balances": []}`)
+		default:
+			notFound(w)
+		}
+	})()
+
+	if seq, err := colon.MLatestLedger(); err != nil || seq != 500 {
+		t.Errorf("latest ledger %d %v, expected 500", seq, err)
+	}
+	for _, c := range []struct {
+		mark  colon.NetworkMark
+		reset bool
+	}{
+		{colon.NetworkMark{Ledger: 400, Accounts: []string{"GEXISTS"}}, false},
+		{colon.NetworkMark{Ledger: 900, Accounts: []string{"GEXISTS"}}, true},
+		{colon.NetworkMark{Ledger: 400, Accounts: []string{"GEXISTS", "GMISSING"}}, true},
+	} {
+		reset, reason, err := colon.MDetectReset(c.mark)
+		if err != nil || reset != c.reset {
+			t.Errorf("mark %+v: reset %v (%s) %v, expected %v", c.mark, reset, reason, err, c.reset)
+		}
+	}
+}
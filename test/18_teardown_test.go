@@ -0,0 +1,102 @@
+package test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/8manuel/colongo/colon/colontest"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+// TestTeardown creates an account X with a trustline and a balance of an asset of an account Y, a cosigner Z and a hash-x signer with the high threshold 2,
+// then tears down X into Y signed by X and Z.
+func TestTeardown(t *testing.T) {
+	namespace := "teardown/" + strconv.FormatInt(time.Now().Unix(), 10)
+	pairX, pairY, pairZ := colon.DeterministicKeypair(namespace+"/X"), colon.DeterministicKeypair(namespace+"/Y"), colon.DeterministicKeypair(namespace+"/Z")
+	for _, addr := range []string{pairX.Address(), pairY.Address()} {
+		if err := colon.MFund(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := colon.MTransTrust(pairX, "TDN", pairY.Address(), colon.MustParseAmount("100"), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := colon.MTransPayment(pairY, pairX.Address(), "TDN", colon.MustParseAmount("10"), false); err != nil {
+		t.Fatal(err)
+	}
+	rawZ, err := strkey.Decode(strkey.VersionByteAccountID, pairZ.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keyZ [32]byte
+	copy(keyZ[:], rawZ)
+	for _, opts := range []map[string]interface{}{
+		{"Signer": []interface{}{int32(xdr.SignerKeyTypeSignerKeyTypeEd25519), keyZ, uint32(1)}},
+		{"Signer": []interface{}{int32(xdr.SignerKeyTypeSignerKeyTypeHashX), sha256.Sum256([]byte(namespace)), uint32(1)}, "HighThreshold": uint32(2)},
+	} {
+		if _, err := colon.MSetOptions(pairX, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the TDN are returned to Y, the trustline and the signers removed and X merged into Y
+	results, err := colon.MTeardownAll([]colon.Signer{pairX}, pairY.Address(), pairZ)
+	colontest.ExpectSuccess(t, err)
+	if len(results) != 1 || !results[0].Merged {
+		t.Errorf("wrong results %+v", results)
+	}
+	if _, err = colon.MLoadBalances(pairX.Address()); err == nil {
+		t.Error("account X exists after the teardown")
+	}
+
+	// tearing down an account that does not exist is skipped
+	if _, err = colon.MTeardownAll([]colon.Signer{pairX}, pairY.Address()); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestAccountMerge merges an account X with a trustline into an account Y, checking the subentries and the confirmation first.
+func TestAccountMerge(t *testing.T) {
+	namespace := "merge/" + strconv.FormatInt(time.Now().Unix(), 10)
+	pairX, pairY := colon.DeterministicKeypair(namespace+"/X"), colon.DeterministicKeypair(namespace+"/Y")
+	for _, addr := range []string{pairX.Address(), pairY.Address()} {
+		if err := colon.MFund(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := colon.MTransTrust(pairX, "MRG", pairY.Address(), colon.MustParseAmount("100"), false); err != nil {
+		t.Fatal(err)
+	}
+	yes := func(source, dest string, balance colon.Amount) bool { return true }
+
+	// with a trustline it is not merged without cleanup, and never without confirmation
+	_, _, err := colon.MAccountMerge(pairX, pairY.Address(), colon.MergeOptions{Confirm: yes})
+	if _, ok := err.(*colon.SubentriesError); !ok {
+		t.Errorf("got %v, expected a SubentriesError", err)
+	}
+	_, _, err = colon.MAccountMerge(pairX, pairY.Address(), colon.MergeOptions{Cleanup: true})
+	if err != colon.ErrMergeNotConfirmed {
+		t.Errorf("got %v, expected ErrMergeNotConfirmed", err)
+	}
+	_, _, err = colon.MAccountMerge(pairX, colon.DeterministicKeypair(namespace+"/NoExist").Address(), colon.MergeOptions{Confirm: yes})
+	if !colon.IsAccountNotFound(err) {
+		t.Errorf("got %v, expected an AccountNotFoundError", err)
+	}
+
+	// the XLM transferred is the balance of X less the fee of the merge transaction
+	var balance colon.Amount
+	merged, receipt, err := colon.MAccountMerge(pairX, pairY.Address(), colon.MergeOptions{Cleanup: true, Confirm: func(source, dest string, bal colon.Amount) bool {
+		balance = bal
+		return true
+	}})
+	colontest.ExpectSuccess(t, err)
+	if merged <= 0 || merged > balance {
+		t.Errorf("merged %s, balance %s", merged, balance)
+	}
+	fmt.Println("merged", merged, "XLM", "hash", receipt.Hash)
+}
@@ -0,0 +1,53 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/8manuel/colongo/colon"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+func TestReceiptUndecoded(t *testing.T) {
+	// a transaction applied with a result that can not be decoded is still a receipt, not an error
+	r := colon.NewReceipt(horizon.TransactionSuccess{Hash: "aabb", Ledger: 7, Result: "not xdr"})
+	if r.Hash != "aabb" || r.Ledger != 7 || r.TxCode != colon.TxSuccess || r.DecodeErr == nil {
+		t.Errorf("receipt %+v", r)
+	}
+}
+
+func TestResultXdrCodes(t *testing.T) {
+	// a failed transaction with the inner results of several operation types
+	tr := func(typ xdr.OperationType, set func(*xdr.OperationResultTr)) xdr.OperationResult {
+		r := &xdr.OperationResultTr{Type: typ}
+		set(r)
+		return xdr.OperationResult{Code: xdr.OperationResultCodeOpInner, Tr: r}
+	}
+	results := []xdr.OperationResult{
+		tr(xdr.OperationTypePayment, func(r *xdr.OperationResultTr) { r.PaymentResult = &xdr.PaymentResult{Code: 0} }),
+		tr(xdr.OperationTypeManageOffer, func(r *xdr.OperationResultTr) { r.ManageOfferResult = &xdr.ManageOfferResult{Code: -7} }),
+		tr(xdr.OperationTypeCreatePassiveOffer, func(r *xdr.OperationResultTr) { r.CreatePassiveOfferResult = &xdr.ManageOfferResult{Code: -2} }),
+		tr(xdr.OperationTypePathPayment, func(r *xdr.OperationResultTr) { r.PathPaymentResult = &xdr.PathPaymentResult{Code: -10} }),
+		tr(xdr.OperationTypeInflation, func(r *xdr.OperationResultTr) { r.InflationResult = &xdr.InflationResult{Code: -1} }),
+		tr(xdr.OperationTypeBumpSequence, func(r *xdr.OperationResultTr) { r.BumpSeqResult = &xdr.BumpSequenceResult{Code: -1} }),
+		tr(xdr.OperationTypeSetOptions, func(r *xdr.OperationResultTr) { r.SetOptionsResult = &xdr.SetOptionsResult{Code: -8} }),
+		tr(xdr.OperationTypeManageData, func(r *xdr.OperationResultTr) { r.ManageDataResult = &xdr.ManageDataResult{Code: -2} }),
+		tr(xdr.OperationTypeAccountMerge, func(r *xdr.OperationResultTr) { r.AccountMergeResult = &xdr.AccountMergeResult{Code: -2} }),
+		{Code: xdr.OperationResultCodeOpNoAccount},
+	}
+	want := []colon.ResultCode{colon.OpSuccess, colon.OpUnderfunded, colon.OpSellNoTrust, colon.OpTooFewOffers, colon.OpNotTime, colon.OpBadSeq,
+		colon.OpBadSigner, colon.OpDataNameNotFound, colon.OpNoAccount, colon.OpNoSourceAccount}
+	b64, err := xdr.MarshalBase64(xdr.TransactionResult{FeeCharged: 1000, Result: xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxFailed, Results: &results}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txCode, opCodes, err := colon.MResultXdrCodes(b64)
+	if err != nil || txCode != colon.TxFailed || len(opCodes) != len(want) {
+		t.Fatal(txCode, opCodes, err)
+	}
+	for i := range want {
+		if opCodes[i] != want[i] {
+			t.Errorf("operation %d: %s, want %s", i, opCodes[i], want[i])
+		}
+	}
+}
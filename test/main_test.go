@@ -12,34 +12,70 @@
 // If you get this code from github if this parameter is not changed the keypairs that you can generate may already exist.
 // Therefore I advise to change is when you run the tests. The way to change is to do in your code or to do in the test command line like this
 //  go test -run TestAddrBalance flgBaseSeed=MYCUSTOMBASESEED
+// The keypairs generated from the 24bytes can be guessed, setting also the flag flgSalt the keypairs are derived with a KDF (colon.NewKeyDeriverV1)
+//  go test -run TestAddrBalance flgBaseSeed=MYCUSTOMBASESEED flgSalt=MYCOURSE2018
+// The asset tests keys are embedded in the source, setting the flag flgKeystore they are random keys kept in an encrypted keystore (colon.Keystore)
+//  go test -run TestAssetFund flgKeystore=keys.json flgKeystorePass=MYPASSWORD
 //
 package test
 
 import (
 	"flag"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	"github.com/8manuel/colongo/colon"
+	"github.com/stellar/go/clients/horizon"
 )
 
 var flgAmt *int
 var flgAddr *string
+var flgCSV *string
+var flgExec *bool
+var flgKeystore *string
 
 func TestMain(m *testing.M) {
 
 	var err error
 	// load flags
 	flgBaseSeed := flag.String("flgBaseSeed", "BaseDrillSeedStr20180522", "used to generate deterministic keypairs for the tests")
+	flgSalt := flag.String("flgSalt", "", "if set the deterministic keypairs are derived with the KDF based derivation (v1) using this salt")
 	flgAmt = flag.Int("flgAmt", 1000, "Amount")
 	flgAddr = flag.String("flgAddr", "GBIYBTHFAOEZNBVDFHAAQWD25EG2CVXCC4PQ333PIUQGRVZN5MJEZRHO", "Address")
+	flgCSV = flag.String("flgCSV", "distribution.csv", "CSV file with address,amount[,memo] rows to distribute")
+	flgExec = flag.Bool("flgExec", false, "execute the distribution, otherwise it is only a dry-run")
+	flgKeystore = flag.String("flgKeystore", "", "keystore file with the asset keys (labels asset/issuing and asset/distribution), it is created if it does not exist")
+	flgKeystorePass := flag.String("flgKeystorePass", "", "password of the flgKeystore file")
 	flag.Parse()
 	_, _, _ = err, flgAmt, flgAddr
 	fmt.Println("running with flags", "flgBaseSeed", *flgBaseSeed, "flgAmt", *flgAmt, "flgAddr", *flgAddr, "\n")
 
 	// set the base seed
-	colon.Init(*flgBaseSeed)
+	if *flgSalt == "" {
+		colon.Init(*flgBaseSeed)
+	} else if kd, err := colon.NewKeyDeriverV1(*flgBaseSeed, *flgSalt); err == nil {
+		colon.SetDefaultKeyDeriver(kd)
+	} else {
+		fmt.Println("wrong flgBaseSeed", err)
+		os.Exit(1)
+	}
+	// open the keystore
+	if *flgKeystore != "" {
+		ks, err := colon.OpenKeystore(*flgKeystore, *flgKeystorePass)
+		if os.IsNotExist(err) {
+			ks, err = colon.CreateKeystore(*flgKeystore, *flgKeystorePass)
+		}
+		if err != nil {
+			fmt.Println("wrong flgKeystore", err)
+			os.Exit(1)
+		}
+		colon.SetDefaultKeystore(ks)
+	}
+	// show the colon package messages (by default colon does not log anything)
+	colon.SetLogger(colon.NewWriterLogger(os.Stdout, colon.LevelInfo))
 
 	// execute the rest of tests, m.Run() executes tests in the following order:
 	// for each file test/*.go file sorted alphabetically
@@ -50,3 +86,21 @@ func TestMain(m *testing.M) {
 	// exit
 	os.Exit(v)
 }
+
+// mockHorizon sets a mock horizon network that answers the requests with handler until the returned function is called,
+// the tests use it as defer mockHorizon(handler)().
+func mockHorizon(handler http.HandlerFunc) func() {
+	srv := httptest.NewServer(handler)
+	prev := colon.CurrentNetwork()
+	colon.SetNetwork(colon.Network{Name: "mock", Client: &horizon.Client{URL: srv.URL, HTTP: http.DefaultClient}, Passphrase: prev.Passphrase})
+	return func() {
+		colon.SetNetwork(prev)
+		srv.Close()
+	}
+}
+
+// notFound writes the horizon error of a resource that does not exist (e.g. an account).
+func notFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprint(w, `{"type": "https://stellar.org/horizon-errors/not_found", "title": "Resource Missing", "status": 404}`)
+}